@@ -0,0 +1,273 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/frankielb/gator/internal/database"
+	"github.com/frankielb/gator/internal/rss"
+	"github.com/google/uuid"
+)
+
+// ScrapeSummary counts what happened over one or more scrape cycles
+type ScrapeSummary struct {
+	Feeds       int
+	NotModified int
+	Failed      int
+	Aborted     int
+	Posts       int
+}
+
+func (sum *ScrapeSummary) add(other ScrapeSummary) {
+	sum.Feeds += other.Feeds
+	sum.NotModified += other.NotModified
+	sum.Failed += other.Failed
+	sum.Aborted += other.Aborted
+	sum.Posts += other.Posts
+}
+
+func (sum ScrapeSummary) String() string {
+	return fmt.Sprintf("%d feeds fetched, %d not modified, %d failed, %d aborted, %d new posts",
+		sum.Feeds, sum.NotModified, sum.Failed, sum.Aborted, sum.Posts)
+}
+
+// AggOptions controls how much work each scrape cycle does
+type AggOptions struct {
+	Workers  int
+	Batch    int
+	Schedule Scheduler
+	// disable a feed after this many failures in a row, 0 for never
+	MaxFailures int
+}
+
+func HandlerAgg(s *State, cmd Command) error {
+	if len(cmd.Args) < 1 {
+		return cmd.UsageError("no time between requests given")
+	}
+	timeBetweenReqs, err := time.ParseDuration(cmd.Args[0])
+	if err != nil {
+		return cmd.UsageError("error setting time: %v", err)
+	}
+
+	workers := cmd.Int("workers")
+	batch := cmd.Int("batch")
+	minInterval := cmd.Duration("min-interval")
+	maxInterval := cmd.Duration("max-interval")
+	if workers < 1 {
+		return cmd.UsageError("workers must be at least 1")
+	}
+	if batch < 1 {
+		batch = workers
+	}
+	if minInterval <= 0 {
+		minInterval = timeBetweenReqs
+	}
+	if maxInterval < minInterval {
+		return cmd.UsageError("max-interval must not be shorter than min-interval")
+	}
+	opts := AggOptions{
+		Workers: workers,
+		Batch:   batch,
+		Schedule: Scheduler{
+			MinInterval: minInterval,
+			MaxInterval: maxInterval,
+		},
+		MaxFailures: cmd.Int("max-failures"),
+	}
+
+	// cancelled on Ctrl-C or a systemd stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("checking for due feeds every %v, up to %d at a time with %d workers\n", timeBetweenReqs, opts.Batch, opts.Workers)
+	ticker := time.NewTicker(timeBetweenReqs)
+	defer ticker.Stop()
+
+	var total ScrapeSummary
+	cycles := 0
+	for {
+		summary, err := ScrapeFeeds(ctx, s, opts)
+		total.add(summary)
+		cycles++
+		if err != nil && ctx.Err() == nil {
+			fmt.Printf("error: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Printf("shutting down after %d cycles: %v\n", cycles, total)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScrapeFeeds claims a batch of due feeds and fetches them in parallel
+func ScrapeFeeds(ctx context.Context, s *State, opts AggOptions) (ScrapeSummary, error) {
+	var summary ScrapeSummary
+	feeds, err := s.Db.ClaimFeedsToFetch(ctx, int32(opts.Batch))
+	if err != nil {
+		return summary, fmt.Errorf("error claiming feeds: %v", err)
+	}
+
+	var mu sync.Mutex
+	jobs := make(chan database.Feed)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for feed := range jobs {
+				// one bad feed shouldn't stop the rest of the batch
+				posts, err := scrapeFeed(ctx, s, &feed)
+				if ctx.Err() == nil {
+					if err := recordHealth(ctx, s, &feed, err, opts.MaxFailures); err != nil {
+						fmt.Printf("error recording health of %s: %v\n", feed.Name, err)
+					}
+					var retryAfter time.Duration
+					var httpErr *rss.HTTPError
+					if errors.As(err, &httpErr) {
+						retryAfter = httpErr.RetryAfter
+					}
+					if err := opts.Schedule.scheduleFeed(ctx, s, feed, retryAfter); err != nil {
+						fmt.Printf("error scheduling %s: %v\n", feed.Name, err)
+					}
+				}
+
+				mu.Lock()
+				summary.Posts += posts
+				switch {
+				case err == nil:
+					summary.Feeds++
+				case errors.Is(err, rss.ErrNotModified):
+					summary.NotModified++
+					fmt.Printf("Feed %s not modified\n", feed.Name)
+				case ctx.Err() != nil:
+					summary.Aborted++
+				default:
+					summary.Failed++
+					fmt.Printf("error scraping %s: %v\n", feed.Name, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, feed := range feeds {
+		select {
+		case jobs <- feed:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return summary, ctx.Err()
+}
+
+// scrapeFeed fetches one feed and saves its posts, returning how many were new.
+// The feed's polling hints are updated in place for the scheduler.
+func scrapeFeed(ctx context.Context, s *State, nextFeed *database.Feed) (int, error) {
+	feed, err := rss.FetchFeed(ctx, nextFeed.Url, rss.CacheHeaders{
+		ETag:         nextFeed.Etag.String,
+		LastModified: nextFeed.LastModified.String,
+	})
+	if err != nil {
+		if errors.Is(err, rss.ErrNotModified) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("error getting feed from url: %w", err)
+	}
+	if err := s.Db.UpdateFeedCache(ctx, database.UpdateFeedCacheParams{
+		ID:           nextFeed.ID,
+		Etag:         nullString(feed.Cache.ETag),
+		LastModified: nullString(feed.Cache.LastModified),
+	}); err != nil {
+		return 0, fmt.Errorf("error saving feed cache headers: %v", err)
+	}
+	hints := database.UpdateFeedHintsParams{
+		ID:        nextFeed.ID,
+		SkipHours: hoursMask(feed.SkipHours),
+		SkipDays:  daysMask(feed.SkipDays),
+	}
+	if feed.TTL > 0 {
+		hints.TtlMinutes = sql.NullInt32{
+			Int32: int32(feed.TTL / time.Minute),
+			Valid: true,
+		}
+	}
+	if err := s.Db.UpdateFeedHints(ctx, hints); err != nil {
+		return 0, fmt.Errorf("error saving feed hints: %v", err)
+	}
+	if feed.Link != "" && feed.Link != nextFeed.SiteUrl.String {
+		if err := s.Db.SetFeedSiteURL(ctx, database.SetFeedSiteURLParams{
+			ID:      nextFeed.ID,
+			SiteUrl: nullString(feed.Link),
+		}); err != nil {
+			return 0, fmt.Errorf("error saving site url: %v", err)
+		}
+	}
+	nextFeed.TtlMinutes = hints.TtlMinutes
+	nextFeed.SkipHours = hints.SkipHours
+	nextFeed.SkipDays = hints.SkipDays
+
+	saved := 0
+	for _, i := range feed.Items {
+		// stop between posts rather than halfway through one
+		if err := ctx.Err(); err != nil {
+			return saved, err
+		}
+		//fmt.Println(i.Title)
+		postID := uuid.New()
+		now := time.Now()
+
+		// to get time in sql form
+		var publishedAt sql.NullTime
+		if !i.PublishedAt.IsZero() {
+			publishedAt = sql.NullTime{
+				Time:  i.PublishedAt,
+				Valid: true,
+			}
+		}
+		var description sql.NullString
+		if i.Description != "" {
+			description = sql.NullString{
+				String: i.Description,
+				Valid:  true,
+			}
+		}
+		_, err := s.Db.CreatePost(ctx, database.CreatePostParams{
+			ID:          postID,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Title:       i.Title,
+			Url:         i.Link,
+			Description: description,
+			PublishedAt: publishedAt,
+			FeedID:      nextFeed.ID,
+		})
+		if err != nil {
+			// Check if it's a duplicate URL error
+			if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+				// Just log and continue
+				fmt.Printf("Skipping duplicate post: %s\n", i.Title)
+				continue
+			}
+			// For other errors, log them but continue processing
+			fmt.Printf("Error creating post: %v\n", err)
+			continue
+		}
+		saved++
+	}
+	return saved, nil
+
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/frankielb/gator/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+// sessionTTL is how long a login lasts before asking for the password again
+const sessionTTL = 30 * 24 * time.Hour
+
+// stdin is shared so piped input isn't lost between prompts
+var stdin = bufio.NewReader(os.Stdin)
+
+// promptPassword reads a password without echoing it, or a plain line when
+// stdin isn't a terminal so scripts can pipe one in
+func promptPassword(label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("error reading password: %v", err)
+		}
+		return string(password), nil
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("error reading password: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// newPasswordHash asks for a new password twice and hashes it
+func newPasswordHash() (sql.NullString, error) {
+	password, err := promptPassword("New password: ")
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	if password == "" {
+		return sql.NullString{}, fmt.Errorf("password can't be empty")
+	}
+	confirm, err := promptPassword("Repeat password: ")
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	if password != confirm {
+		return sql.NullString{}, fmt.Errorf("passwords don't match")
+	}
+	return hashPassword(password)
+}
+
+func hashPassword(password string) (sql.NullString, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("error hashing password: %v", err)
+	}
+	return sql.NullString{String: string(hash), Valid: true}, nil
+}
+
+func checkPassword(user database.User, password string) bool {
+	if !user.PasswordHash.Valid {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(password)) == nil
+}
+
+// newToken makes a random secret, only its hash is stored in the database
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// startSession logs user in, replacing any session saved in the config
+func startSession(ctx context.Context, s *State, user database.User) error {
+	if old := s.CurrentConfig.SessionToken; old != "" {
+		if err := s.Db.DeleteSession(ctx, hashToken(old)); err != nil {
+			return fmt.Errorf("error ending old session: %v", err)
+		}
+	}
+	token, err := newToken()
+	if err != nil {
+		return fmt.Errorf("error creating session: %v", err)
+	}
+	err = s.Db.CreateSession(ctx, database.CreateSessionParams{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(sessionTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating session: %v", err)
+	}
+	return s.CurrentConfig.SetSession(user.Name, token)
+}
+
+// currentUser is the user whose session token is in the config
+func currentUser(ctx context.Context, s *State) (database.User, error) {
+	token := s.CurrentConfig.SessionToken
+	if token == "" {
+		return database.User{}, fmt.Errorf("not logged in, run 'gator login <name>'")
+	}
+	user, err := s.Db.GetSessionUser(ctx, hashToken(token))
+	if err == sql.ErrNoRows {
+		return database.User{}, fmt.Errorf("session expired, run 'gator login %s'", s.CurrentConfig.CurrentUserName)
+	}
+	if err != nil {
+		return database.User{}, fmt.Errorf("error checking session: %v", err)
+	}
+	return user, nil
+}
+
+// HandlerPasswd changes the current user's password, or with --user lets an
+// admin set another user's
+func HandlerPasswd(s *State, cmd Command) error {
+	name := cmd.String("user")
+	if name == "" {
+		return MiddlewareLoggedIn(handlerPasswdSelf)(s, cmd)
+	}
+	ctx := context.Background()
+	hasAdmin, err := s.Db.HasAdmin(ctx)
+	if err != nil {
+		return fmt.Errorf("error checking for an admin: %v", err)
+	}
+	if !hasAdmin {
+		// an install from before admins, nobody is trusted to claim accounts
+		return fmt.Errorf("there's no admin yet, make one in the database with: UPDATE users SET is_admin = true WHERE name = '<name>';")
+	}
+	admin, err := currentUser(ctx, s)
+	if err != nil {
+		return err
+	}
+	if !admin.IsAdmin {
+		return fmt.Errorf("only an admin can set another user's password")
+	}
+
+	user, err := s.Db.GetUser(ctx, name)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user '%v' does not exist", name)
+	}
+	if err != nil {
+		return fmt.Errorf("error finding user '%v': %v", name, err)
+	}
+	if admin.ID == user.ID {
+		return handlerPasswdSelf(s, cmd, user)
+	}
+
+	hash, err := newPasswordHash()
+	if err != nil {
+		return err
+	}
+	if err := setPassword(ctx, s, user, hash); err != nil {
+		return err
+	}
+	fmt.Printf("Password set for %s, any of their sessions have been logged out\n", user.Name)
+	return nil
+}
+
+// handlerPasswdSelf changes the logged in user's password and logs out their other sessions
+func handlerPasswdSelf(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+	if user.PasswordHash.Valid {
+		password, err := promptPassword("Current password: ")
+		if err != nil {
+			return err
+		}
+		if !checkPassword(user, password) {
+			return fmt.Errorf("wrong password")
+		}
+	}
+	hash, err := newPasswordHash()
+	if err != nil {
+		return err
+	}
+	if err := setPassword(ctx, s, user, hash); err != nil {
+		return err
+	}
+	s.CurrentConfig.SessionToken = ""
+	if err := startSession(ctx, s, user); err != nil {
+		return err
+	}
+	fmt.Println("Password changed, any other sessions have been logged out")
+	return nil
+}
+
+// setPassword saves a new password hash and ends all of the user's sessions
+func setPassword(ctx context.Context, s *State, user database.User, hash sql.NullString) error {
+	err := s.Db.SetUserPassword(ctx, database.SetUserPasswordParams{
+		ID:           user.ID,
+		PasswordHash: hash,
+	})
+	if err != nil {
+		return fmt.Errorf("error saving password: %v", err)
+	}
+	if err := s.Db.DeleteUserSessions(ctx, user.ID); err != nil {
+		return fmt.Errorf("error ending sessions: %v", err)
+	}
+	return nil
+}
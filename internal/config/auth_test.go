@@ -0,0 +1,55 @@
+package config
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/frankielb/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// TestPasswdUserNeedsAdmin checks --user never sets a password, or hands out
+// admin, for someone who isn't already an admin
+func TestPasswdUserNeedsAdmin(t *testing.T) {
+	tests := []struct {
+		name     string
+		hasAdmin bool
+		wantErr  string
+	}{
+		{"no admin yet", false, "there's no admin yet"},
+		{"not an admin", true, "only an admin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("error creating mock database: %v", err)
+			}
+			defer db.Close()
+			s := &State{CurrentConfig: &Config{SessionToken: "session"}, Db: database.New(db), Conn: db}
+
+			mock.ExpectQuery(query("HasAdmin")).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.hasAdmin))
+			if tt.hasAdmin {
+				user := database.User{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Name: "bob"}
+				mock.ExpectQuery(query("GetSessionUser")).
+					WithArgs(hashToken("session")).
+					WillReturnRows(sqlmock.NewRows(userColumns).AddRow(userValues(user)...))
+			}
+
+			flags := flag.NewFlagSet("passwd", flag.ContinueOnError)
+			flags.String("user", "", "")
+			flags.Set("user", "alice")
+			err = HandlerPasswd(s, Command{Name: "passwd", Flags: flags})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet database expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CommandInfo describes a command for parsing and for help
+type CommandInfo struct {
+	Description string
+	// usage line without the leading "gator", e.g. "follow <url>"
+	Usage   string
+	Flags   []Flag
+	MinArgs int
+	// -1 for no limit
+	MaxArgs int
+	// fixed words for the first argument, e.g. subcommands
+	ArgWords []string
+	// dynamic values for the first argument, see Completion
+	Complete Completion
+	// left out of help and completion
+	Hidden bool
+	// runs without the config file or a database, like help
+	NoDB bool
+}
+
+// Flag is a typed flag, the type comes from Default (bool, string, int or time.Duration)
+type Flag struct {
+	Name    string
+	Default any
+	Usage   string
+}
+
+// UsageError is returned when a command is called with bad arguments
+type UsageError struct {
+	Msg   string
+	Usage string
+}
+
+func (e *UsageError) Error() string {
+	if e.Msg == "" {
+		return fmt.Sprintf("usage: gator %s", e.Usage)
+	}
+	return fmt.Sprintf("%s\nusage: gator %s", e.Msg, e.Usage)
+}
+
+// UsageError builds a usage error for this command with a reason
+func (c Command) UsageError(format string, a ...any) error {
+	return &UsageError{
+		Msg:   fmt.Sprintf(format, a...),
+		Usage: c.Usage,
+	}
+}
+
+func (c Command) flagValue(name string) any {
+	if c.Flags == nil {
+		return nil
+	}
+	f := c.Flags.Lookup(name)
+	if f == nil {
+		return nil
+	}
+	return f.Value.(flag.Getter).Get()
+}
+
+func (c Command) Bool(name string) bool {
+	v, _ := c.flagValue(name).(bool)
+	return v
+}
+
+func (c Command) String(name string) string {
+	v, _ := c.flagValue(name).(string)
+	return v
+}
+
+func (c Command) Int(name string) int {
+	v, _ := c.flagValue(name).(int)
+	return v
+}
+
+func (c Command) Duration(name string) time.Duration {
+	v, _ := c.flagValue(name).(time.Duration)
+	return v
+}
+
+type Commands struct {
+	Handlers map[string]func(*State, Command) error
+	Info     map[string]CommandInfo
+	// Connect loads the config and opens the database. Run only calls it once
+	// a command is about to run and needs them, so help works without a config.
+	Connect func(*State) error
+}
+
+func (c *Commands) Register(name string, f func(*State, Command) error, info CommandInfo) {
+	if c.Handlers == nil {
+		c.Handlers = make(map[string]func(*State, Command) error)
+	}
+	if c.Info == nil {
+		c.Info = make(map[string]CommandInfo)
+	}
+	if info.Usage == "" {
+		info.Usage = name
+	}
+	c.Handlers[name] = f
+	c.Info[name] = info
+}
+
+// flagSet builds the flag set for a command, output goes nowhere so errors
+// are reported once as a UsageError
+func (info CommandInfo) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	for _, f := range info.Flags {
+		switch def := f.Default.(type) {
+		case bool:
+			flags.Bool(f.Name, def, f.Usage)
+		case string:
+			flags.String(f.Name, def, f.Usage)
+		case int:
+			flags.Int(f.Name, def, f.Usage)
+		case time.Duration:
+			flags.Duration(f.Name, def, f.Usage)
+		default:
+			panic(fmt.Sprintf("flag --%s has unsupported type %T", f.Name, f.Default))
+		}
+	}
+	return flags
+}
+
+// parseFlags lets flags come before or after positional args, unlike flag.Parse
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// ExtractGlobal pulls a global flag (--name value, -name value or --name=value)
+// out of args, wherever it is. The last one wins.
+func ExtractGlobal(args []string, name string) (string, []string, error) {
+	return extractFlag(args, name, "")
+}
+
+func extractFlag(args []string, name, short string) (string, []string, error) {
+	var value string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--"+name || arg == "-"+name || (short != "" && arg == "-"+short):
+			if i+1 >= len(args) {
+				return "", nil, fmt.Errorf("--%s needs a value", name)
+			}
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--"+name+"="):
+			value = strings.TrimPrefix(arg, "--"+name+"=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return value, rest, nil
+}
+
+// extractOutput pulls the global --output flag out of args
+func extractOutput(args []string) (string, []string, error) {
+	format, rest, err := extractFlag(args, "output", "o")
+	if err != nil {
+		return "", nil, err
+	}
+	// no --output means each command's usual text
+	if format == "" {
+		return "", rest, nil
+	}
+	if !validOutput(format) {
+		return "", nil, fmt.Errorf("unknown output format %q, use json, csv, ndjson or table", format)
+	}
+	return format, rest, nil
+}
+
+func (c *Commands) Run(s *State, cmd Command) error {
+	handler, exists := c.Handlers[cmd.Name]
+	if !exists {
+		return fmt.Errorf("Command not found: %s (see 'gator help')", cmd.Name)
+	}
+	info := c.Info[cmd.Name]
+
+	format, args, err := extractOutput(cmd.Args)
+	if err != nil {
+		return err
+	}
+	s.Output = format
+
+	cmd.Usage = info.Usage
+	cmd.Flags = info.flagSet(cmd.Name)
+	cmd.Args, err = parseFlags(cmd.Flags, args)
+	if errors.Is(err, flag.ErrHelp) {
+		c.printUsage(os.Stdout, cmd.Name)
+		return nil
+	}
+	if err != nil {
+		return cmd.UsageError("%v", err)
+	}
+	if len(cmd.Args) < info.MinArgs {
+		return cmd.UsageError("not enough arguments")
+	}
+	if info.MaxArgs >= 0 && len(cmd.Args) > info.MaxArgs {
+		return cmd.UsageError("too many arguments")
+	}
+	if !info.NoDB && c.Connect != nil {
+		if err := c.Connect(s); err != nil {
+			return err
+		}
+	}
+	return handler(s, cmd)
+}
+
+// HandlerHelp lists the commands, or explains one in detail
+func (c *Commands) HandlerHelp(s *State, cmd Command) error {
+	if len(cmd.Args) > 0 {
+		if _, ok := c.Info[cmd.Args[0]]; !ok {
+			return fmt.Errorf("Command not found: %s", cmd.Args[0])
+		}
+		c.printUsage(os.Stdout, cmd.Args[0])
+		return nil
+	}
+
+	names := make([]string, 0, len(c.Info))
+	width := 0
+	for name, info := range c.Info {
+		if info.Hidden {
+			continue
+		}
+		names = append(names, name)
+		width = max(width, len(name))
+	}
+	sort.Strings(names)
+
+	fmt.Println("usage: gator <command> [args] [--output json|csv|ndjson|table] [--db url]")
+	fmt.Println()
+	fmt.Println("commands:")
+	for _, name := range names {
+		fmt.Printf("  %-*s  %s\n", width, name, c.Info[name].Description)
+	}
+	fmt.Println()
+	fmt.Println("Run 'gator help <command>' or 'gator <command> --help' for details.")
+	return nil
+}
+
+func (c *Commands) printUsage(w io.Writer, name string) {
+	info := c.Info[name]
+	fmt.Fprintf(w, "usage: gator %s\n", info.Usage)
+	if info.Description != "" {
+		fmt.Fprintf(w, "\n%s\n", info.Description)
+	}
+	if len(info.Flags) > 0 {
+		fmt.Fprintln(w, "\nflags:")
+		flags := info.flagSet(name)
+		flags.SetOutput(w)
+		flags.PrintDefaults()
+	}
+}
@@ -3,34 +3,42 @@ package config
 import (
 	"context"
 	"database/sql"
+	"flag"
 	"fmt"
+	"html"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/frankielb/gator/internal/database"
-	"github.com/frankielb/gator/internal/rss"
 	"github.com/google/uuid"
 )
 
 type State struct {
 	CurrentConfig *Config
 	Db            *database.Queries
+	// raw connection, for the few things sqlc doesn't cover like migrations
+	Conn *sql.DB
+	// one of the Output* formats, set from --output by Commands.Run
+	Output string
 }
 
 type Command struct {
 	Name string
-	Args []string
+	// positional args, flags declared in CommandInfo are parsed out by Commands.Run
+	Args  []string
+	Flags *flag.FlagSet
+	Usage string
 }
 
 func HandlerLogin(s *State, cmd Command) error {
 	if len(cmd.Args) == 0 {
-		return fmt.Errorf("no username given")
+		return cmd.UsageError("no username given")
 	}
 	name := cmd.Args[0]
+	ctx := context.Background()
 
-	_, err := s.Db.GetUser(context.Background(), name)
+	user, err := s.Db.GetUser(ctx, name)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			fmt.Printf("user '%v' does not exist\n", name)
@@ -39,7 +47,19 @@ func HandlerLogin(s *State, cmd Command) error {
 		return fmt.Errorf("error checking for existing user '%v'", err)
 	}
 
-	if err := s.CurrentConfig.SetUser(name); err != nil {
+	// users from before passwords existed can't log in until one is set for them
+	if !user.PasswordHash.Valid {
+		return fmt.Errorf("user '%v' has no password yet, an admin can set one with 'gator passwd --user %v'", name, name)
+	}
+	password, err := promptPassword("Password: ")
+	if err != nil {
+		return err
+	}
+	if !checkPassword(user, password) {
+		return fmt.Errorf("wrong password for user '%v'", name)
+	}
+
+	if err := startSession(ctx, s, user); err != nil {
 		return err
 	}
 
@@ -49,7 +69,7 @@ func HandlerLogin(s *State, cmd Command) error {
 
 func HandlerRegister(s *State, cmd Command) error {
 	if len(cmd.Args) == 0 {
-		return fmt.Errorf("no username given")
+		return cmd.UsageError("no username given")
 	}
 	name := cmd.Args[0]
 
@@ -63,28 +83,37 @@ func HandlerRegister(s *State, cmd Command) error {
 		os.Exit(1)
 	}
 
+	hash, err := newPasswordHash()
+	if err != nil {
+		return err
+	}
+
 	newUser := uuid.New()
 	now := time.Now()
 
 	//make the new user
 	user, err := s.Db.CreateUser(context.Background(), database.CreateUserParams{
-		ID:        newUser,
-		CreatedAt: now,
-		UpdatedAt: now,
-		Name:      name,
+		ID:           newUser,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Name:         name,
+		PasswordHash: hash,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create user: %v", err)
 	}
 
-	if err := s.CurrentConfig.SetUser(name); err != nil {
+	if err := startSession(context.Background(), s, user); err != nil {
 		return err
 	}
 	fmt.Printf("Successfully registered user %s \n", user.Name)
+	if user.IsAdmin {
+		fmt.Println("As the first user, you're the admin")
+	}
 	return nil
 }
 
-func HandlerReset(s *State, cmd Command) error {
+func HandlerReset(s *State, cmd Command, user database.User) error {
 	err := s.Db.DeleteUsers(context.Background())
 	if err != nil {
 		return fmt.Errorf("error resetting database: %v", err)
@@ -99,6 +128,13 @@ func HandlerUsers(s *State, cmd Command) error {
 		return fmt.Errorf("error getting users: %v", err)
 	}
 
+	if s.Structured() {
+		records := Records{Fields: []string{"name", "current"}}
+		for _, user := range users {
+			records.Add(user, user == s.CurrentConfig.CurrentUserName)
+		}
+		return s.Render(records)
+	}
 	for _, user := range users {
 		if user == s.CurrentConfig.CurrentUserName {
 			fmt.Printf("* %s (current)\n", user)
@@ -109,28 +145,9 @@ func HandlerUsers(s *State, cmd Command) error {
 	return nil
 }
 
-func HandlerAgg(s *State, cmd Command) error {
-	if len(cmd.Args) < 1 {
-		return fmt.Errorf("usage: agg <time between requests>")
-	}
-	timeBetweenReqs, err := time.ParseDuration(cmd.Args[0])
-	if err != nil {
-		return fmt.Errorf("error setting time: %v", err)
-	}
-
-	fmt.Printf("collecting feeds every %v\n", timeBetweenReqs)
-	ticker := time.NewTicker(timeBetweenReqs)
-	for ; ; <-ticker.C {
-		if err := ScrapeFeeds(s); err != nil {
-			fmt.Printf("error: %v\n", err)
-		}
-	}
-
-}
-
 func HandlerAddFeed(s *State, cmd Command, user database.User) error {
 	if len(cmd.Args) < 2 {
-		return fmt.Errorf("no name or URL given")
+		return cmd.UsageError("no name or URL given")
 	}
 	name := cmd.Args[0]
 	url := cmd.Args[1]
@@ -174,10 +191,27 @@ func HandlerAddFeed(s *State, cmd Command, user database.User) error {
 }
 
 func HandlerFeeds(s *State, cmd Command) error {
+	if len(cmd.Args) > 0 {
+		switch cmd.Args[0] {
+		case "health":
+			return handlerFeedsHealth(s, cmd)
+		case "enable":
+			return handlerFeedsEnable(s, cmd)
+		default:
+			return cmd.UsageError("unknown feeds subcommand: %s", cmd.Args[0])
+		}
+	}
 	feeds, err := s.Db.GetFeeds(context.Background())
 	if err != nil {
 		return fmt.Errorf("error getting feeds: %v", err)
 	}
+	if s.Structured() {
+		records := Records{Fields: []string{"name", "url", "site_url", "user"}}
+		for _, feed := range feeds {
+			records.Add(feed.Name, feed.Url, feed.SiteUrl, feed.Username)
+		}
+		return s.Render(records)
+	}
 	for _, feed := range feeds {
 		fmt.Printf("Feed: %v\n -URL: %v\n -Username: %v\n\n", feed.Name, feed.Url, feed.Username)
 	}
@@ -186,7 +220,7 @@ func HandlerFeeds(s *State, cmd Command) error {
 
 func HandlerFollow(s *State, cmd Command, user database.User) error {
 	if len(cmd.Args) == 0 {
-		return fmt.Errorf("no url given")
+		return cmd.UsageError("no url given")
 	}
 	url := cmd.Args[0]
 	//currentUser := s.CurrentConfig.CurrentUserName
@@ -232,6 +266,13 @@ func HandlerFollowing(s *State, cmd Command, user database.User) error {
 	if err != nil {
 		return fmt.Errorf("error getting following: %v", err)
 	}
+	if s.Structured() {
+		records := Records{Fields: []string{"feed_name", "feed_url", "category", "followed_at"}}
+		for _, follow := range follows {
+			records.Add(follow.FeedName, follow.FeedUrl, follow.Category, follow.CreatedAt)
+		}
+		return s.Render(records)
+	}
 	if len(follows) == 0 {
 		fmt.Println("You aren't following any feeds yet.")
 		return nil
@@ -244,7 +285,7 @@ func HandlerFollowing(s *State, cmd Command, user database.User) error {
 
 func HandlerUnfollow(s *State, cmd Command, user database.User) error {
 	if len(cmd.Args) == 0 {
-		return fmt.Errorf("no url given")
+		return cmd.UsageError("no url given")
 	}
 	url := cmd.Args[0]
 	err := s.Db.DeleteFeedByUser(context.Background(), database.DeleteFeedByUserParams{
@@ -257,6 +298,66 @@ func HandlerUnfollow(s *State, cmd Command, user database.User) error {
 	return nil
 }
 
+// BrowseOptions are the filters and paging shared by browse and the API
+type BrowseOptions struct {
+	Limit int
+	// counts from 1, ignored if Offset is 0 or more
+	Page   int
+	Offset int
+	// published or fetched
+	Sort   string
+	Asc    bool
+	Unread bool
+	// feed url or name
+	Feed string
+	// YYYY-MM-DD or RFC3339, empty for no bound
+	Since string
+	Until string
+}
+
+// params checks the options and turns them into the query params, along with
+// how many posts are skipped
+func (o BrowseOptions) params(userID uuid.UUID) (database.GetPostsForUserParams, int, error) {
+	if o.Limit < 1 {
+		return database.GetPostsForUserParams{}, 0, fmt.Errorf("limit must be at least 1")
+	}
+	if o.Sort != "published" && o.Sort != "fetched" {
+		return database.GetPostsForUserParams{}, 0, fmt.Errorf("sort must be published or fetched")
+	}
+	if o.Page < 1 {
+		return database.GetPostsForUserParams{}, 0, fmt.Errorf("page must be at least 1")
+	}
+	skip := (o.Page - 1) * o.Limit
+	if o.Offset >= 0 {
+		skip = o.Offset
+	}
+
+	params := database.GetPostsForUserParams{
+		UserID:      userID,
+		UnreadOnly:  o.Unread,
+		Feed:        nullString(o.Feed),
+		SortFetched: o.Sort == "fetched",
+		SortAsc:     o.Asc,
+		PageSize:    int32(o.Limit),
+		PageOffset:  int32(skip),
+	}
+	if o.Since != "" {
+		t, err := parseDateFlag(o.Since)
+		if err != nil {
+			return database.GetPostsForUserParams{}, 0, err
+		}
+		params.Since = sql.NullTime{Time: t, Valid: true}
+	}
+	if o.Until != "" {
+		t, err := parseDateFlag(o.Until)
+		if err != nil {
+			return database.GetPostsForUserParams{}, 0, err
+		}
+		params.Until = sql.NullTime{Time: t, Valid: true}
+	}
+	return params, skip, nil
+}
+
 func HandlerBrowse(s *State, cmd Command, user database.User) error {
 	var limit int = 2
 
@@ -264,138 +365,82 @@ func HandlerBrowse(s *State, cmd Command, user database.User) error {
 		// Try to parse the first argument as an integer
 		parsedLimit, err := strconv.Atoi(cmd.Args[0])
 		if err != nil {
-			return fmt.Errorf("limit must be a number: %v", err)
+			return cmd.UsageError("limit must be a number: %v", err)
 		}
 		limit = parsedLimit
 	}
-	posts, err := s.Db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
-		UserID: user.ID,
-		Limit:  int32(limit),
-	})
+	params, skip, err := BrowseOptions{
+		Limit:  limit,
+		Page:   cmd.Int("page"),
+		Offset: cmd.Int("offset"),
+		Sort:   cmd.String("sort"),
+		Asc:    cmd.Bool("asc"),
+		Unread: cmd.Bool("unread"),
+		Feed:   cmd.String("feed"),
+		Since:  cmd.String("since"),
+		Until:  cmd.String("until"),
+	}.params(user.ID)
+	if err != nil {
+		return cmd.UsageError("%v", err)
+	}
+	posts, err := s.Db.GetPostsForUser(context.Background(), params)
 	if err != nil {
 		return fmt.Errorf("error getting posts: %v", err)
 	}
+	if s.Structured() {
+		records := Records{Fields: []string{"id", "title", "url", "description", "published_at", "fetched_at", "feed_id", "read"}}
+		for _, post := range posts {
+			records.Add(post.ID, html.UnescapeString(post.Title), post.Url, unescapeNull(post.Description), post.PublishedAt, post.CreatedAt, post.FeedID, post.IsRead)
+		}
+		return s.Render(records)
+	}
+	unreadCount, err := s.Db.CountUnreadPostsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error counting unread posts: %v", err)
+	}
 	if len(posts) == 0 {
 		fmt.Println("no posts found")
 		return nil
 	}
-	fmt.Printf("Found %d posts:\n\n", len(posts))
+	fmt.Printf("Found %d posts (%d unread in total):\n\n", len(posts), unreadCount)
 	for i, post := range posts {
-		fmt.Printf("%d. %s\n", i+1, post.Title)
+		marker := ""
+		if !post.IsRead {
+			marker = " [unread]"
+		}
+		// number posts across pages
+		fmt.Printf("%d. %s%s\n", skip+i+1, post.Title, marker)
+		fmt.Printf("   ID: %s\n", post.ID)
 		fmt.Printf("   URL: %s\n", post.Url)
 		if post.Description.Valid {
 			fmt.Printf("   %s\n", post.Description.String)
 		}
-		fmt.Printf("   Published: %s\n", post.PublishedAt.Time)
+		if post.PublishedAt.Valid {
+			fmt.Printf("   Published: %s\n", post.PublishedAt.Time)
+		} else {
+			fmt.Printf("   Fetched: %s\n", post.CreatedAt)
+		}
 		fmt.Println() // Empty line between posts
 	}
 	return nil
 
 }
 
+// MiddlewareLoggedIn passes the user from the session in the config, so a
+// user name alone isn't enough to act as someone
 func MiddlewareLoggedIn(handler func(s *State, cmd Command, user database.User) error) func(*State, Command) error {
 	return func(s *State, cmd Command) error {
-		user, err := s.Db.GetUser(context.Background(), s.CurrentConfig.CurrentUserName)
+		user, err := currentUser(context.Background(), s)
 		if err != nil {
-			return fmt.Errorf("error finding user id: %v", err)
+			return err
 		}
 		return handler(s, cmd, user)
 	}
 }
 
-type Commands struct {
-	Handlers map[string]func(*State, Command) error
-}
-
-func (c *Commands) Register(name string, f func(*State, Command) error) {
-	if c.Handlers == nil {
-		c.Handlers = make(map[string]func(*State, Command) error)
-	}
-	c.Handlers[name] = f
-}
-func (c *Commands) Run(s *State, cmd Command) error {
-	if handler, exists := c.Handlers[cmd.Name]; exists {
-		return handler(s, cmd)
-	} else {
-		return fmt.Errorf("Command not found: %s", cmd.Name)
-	}
-
-}
-
-func ScrapeFeeds(s *State) error {
-	nextFeed, err := s.Db.GetNextFeedToFetch(context.Background())
-	if err != nil {
-		return fmt.Errorf("error finding next feed: %v", err)
+func nullString(s string) sql.NullString {
+	return sql.NullString{
+		String: s,
+		Valid:  s != "",
 	}
-
-	if err := s.Db.MarkFeedFetched(context.Background(), nextFeed.ID); err != nil {
-		return fmt.Errorf("error marking feed as fetched: %v", err)
-	}
-	feed, err := rss.FetchFeed(context.Background(), nextFeed.Url)
-	if err != nil {
-		return fmt.Errorf("error getting feed from url: %v", err)
-	}
-
-	for _, i := range feed.Channel.Item {
-		//fmt.Println(i.Title)
-		postID := uuid.New()
-		now := time.Now()
-
-		// to get time in sql form
-		var publishedAt sql.NullTime
-		if i.PubDate != "" {
-			// Try RFC1123Z format (common in RSS)
-			pubTime, err := time.Parse(time.RFC1123Z, i.PubDate)
-			if err != nil {
-				// Try RFC1123 format
-				pubTime, err = time.Parse(time.RFC1123, i.PubDate)
-				if err != nil {
-					// Try another common format
-					pubTime, err = time.Parse("2006-01-02T15:04:05Z", i.PubDate)
-					if err != nil {
-						// Log it but continue
-						fmt.Printf("Could not parse date '%s': %v\n", i.PubDate, err)
-						// Leave as null time
-					}
-				}
-			}
-
-			if err == nil {
-				publishedAt = sql.NullTime{
-					Time:  pubTime,
-					Valid: true,
-				}
-			}
-		}
-		var description sql.NullString
-		if i.Description != "" {
-			description = sql.NullString{
-				String: i.Description,
-				Valid:  true,
-			}
-		}
-		_, err := s.Db.CreatePost(context.Background(), database.CreatePostParams{
-			ID:          postID,
-			CreatedAt:   now,
-			UpdatedAt:   now,
-			Title:       i.Title,
-			Url:         i.Link,
-			Description: description,
-			PublishedAt: publishedAt,
-			FeedID:      nextFeed.ID,
-		})
-		if err != nil {
-			// Check if it's a duplicate URL error
-			if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-				// Just log and continue
-				fmt.Printf("Skipping duplicate post: %s\n", i.Title)
-				continue
-			}
-			// For other errors, log them but continue processing
-			fmt.Printf("Error creating post: %v\n", err)
-			continue
-		}
-	}
-	return nil
-
 }
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/frankielb/gator/internal/database"
+)
+
+// Completion names a dynamic list the shell asks gator for when completing an argument
+type Completion int
+
+const (
+	CompleteNone Completion = iota
+	CompleteUsers
+	CompleteFeeds
+	CompleteFollowedFeeds
+	CompletePosts
+	CompleteFiles
+)
+
+// completeCommand is the hidden command the generated scripts call back into
+const completeCommand = "__complete"
+
+// HandlerCompletion prints a completion script for the given shell
+func (c *Commands) HandlerCompletion(s *State, cmd Command) error {
+	if len(cmd.Args) == 0 {
+		return cmd.UsageError("no shell given")
+	}
+	var script string
+	switch cmd.Args[0] {
+	case "bash":
+		script = c.bashCompletion()
+	case "zsh":
+		script = c.zshCompletion()
+	case "fish":
+		script = c.fishCompletion()
+	default:
+		return cmd.UsageError("unsupported shell: %s", cmd.Args[0])
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// HandlerComplete prints the candidates for a command's argument, one per line.
+// It's run on every tab press, so errors, even a missing config, just mean no
+// suggestions.
+func (c *Commands) HandlerComplete(s *State, cmd Command) error {
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+	info, ok := c.Info[cmd.Args[0]]
+	if !ok || info.Complete == CompleteNone || info.Complete == CompleteFiles {
+		return nil
+	}
+	if c.Connect != nil {
+		if err := c.Connect(s); err != nil {
+			return nil
+		}
+	}
+	ctx := context.Background()
+	var words []string
+	switch info.Complete {
+	case CompleteUsers:
+		words, _ = s.Db.GetUsers(ctx)
+	case CompleteFeeds:
+		feeds, _ := s.Db.GetFeeds(ctx)
+		for _, feed := range feeds {
+			words = append(words, feed.Url)
+		}
+	case CompleteFollowedFeeds:
+		user, err := currentUser(ctx, s)
+		if err != nil {
+			return nil
+		}
+		follows, _ := s.Db.GetFeedFollowsUser(ctx, user.ID)
+		for _, follow := range follows {
+			words = append(words, follow.FeedUrl)
+		}
+	case CompletePosts:
+		user, err := currentUser(ctx, s)
+		if err != nil {
+			return nil
+		}
+		posts, _ := s.Db.GetPostsForUser(ctx, database.GetPostsForUserParams{
+			UserID:   user.ID,
+			PageSize: 50,
+		})
+		for _, post := range posts {
+			words = append(words, post.ID.String())
+		}
+	}
+	for _, word := range words {
+		fmt.Fprintln(os.Stdout, word)
+	}
+	return nil
+}
+
+// visibleCommands are the registered commands in name order, without hidden ones
+func (c *Commands) visibleCommands() []string {
+	var names []string
+	for name, info := range c.Info {
+		if !info.Hidden {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Commands) flagNames(name string) []string {
+	names := []string{"--help", "--output", "--db"}
+	for _, f := range c.Info[name].Flags {
+		names = append(names, "--"+f.Name)
+	}
+	return names
+}
+
+func (c *Commands) bashCompletion() string {
+	var b strings.Builder
+	names := c.visibleCommands()
+
+	b.WriteString("# bash completion for gator, load with: source <(gator completion bash)\n")
+	b.WriteString("_gator() {\n")
+	b.WriteString("    local cur prev words cword cmd\n")
+	// bash splits words on the colons in urls, so get them back whole
+	b.WriteString("    if declare -F _get_comp_words_by_ref >/dev/null; then\n")
+	b.WriteString("        _get_comp_words_by_ref -n : cur prev words cword\n")
+	b.WriteString("    else\n")
+	b.WriteString("        local line=\"${COMP_LINE:0:COMP_POINT}\"\n")
+	b.WriteString("        read -ra words <<< \"$line\"\n")
+	b.WriteString("        [[ -z $line || $line == *[[:space:]] ]] && words+=( \"\" )\n")
+	b.WriteString("        cword=$(( ${#words[@]} - 1 ))\n")
+	b.WriteString("        cur=\"${words[cword]}\"\n")
+	b.WriteString("        prev=\"${words[cword-1]}\"\n")
+	b.WriteString("    fi\n")
+	fmt.Fprintf(&b, "    if [[ $cword -eq 1 ]]; then\n        COMPREPLY=( $(compgen -W %q -- \"$cur\") )\n        return\n    fi\n", strings.Join(names, " "))
+	b.WriteString("    cmd=\"${words[1]}\"\n")
+	b.WriteString("    if [[ \"$prev\" == \"--output\" ]]; then\n        COMPREPLY=( $(compgen -W \"json csv ndjson table\" -- \"$cur\") )\n        return\n    fi\n")
+
+	b.WriteString("    if [[ \"$cur\" == -* ]]; then\n        case \"$cmd\" in\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "            %s) COMPREPLY=( $(compgen -W %q -- \"$cur\") ) ;;\n", name, strings.Join(c.flagNames(name), " "))
+	}
+	b.WriteString("        esac\n        return\n    fi\n")
+
+	b.WriteString("    [[ $cword -eq 2 ]] || return\n")
+	b.WriteString("    case \"$cmd\" in\n")
+	for _, name := range names {
+		info := c.Info[name]
+		switch {
+		case len(info.ArgWords) > 0:
+			fmt.Fprintf(&b, "        %s) COMPREPLY=( $(compgen -W %q -- \"$cur\") ) ;;\n", name, strings.Join(info.ArgWords, " "))
+		case info.Complete == CompleteFiles:
+			fmt.Fprintf(&b, "        %s) COMPREPLY=( $(compgen -f -- \"$cur\") ) ;;\n", name)
+		case info.Complete != CompleteNone:
+			fmt.Fprintf(&b, "        %s) COMPREPLY=( $(compgen -W \"$(gator %s %s 2>/dev/null)\" -- \"$cur\") ) ;;\n", name, completeCommand, name)
+		}
+	}
+	b.WriteString("    esac\n")
+	// bash only replaces the text after the last colon, so drop what's before it
+	b.WriteString("    if [[ $cur == *:* && $COMP_WORDBREAKS == *:* ]]; then\n")
+	b.WriteString("        local prefix=${cur%\"${cur##*:}\"} i\n")
+	b.WriteString("        for i in \"${!COMPREPLY[@]}\"; do COMPREPLY[i]=${COMPREPLY[i]#\"$prefix\"}; done\n")
+	b.WriteString("    fi\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -F _gator gator\n")
+	return b.String()
+}
+
+func (c *Commands) zshCompletion() string {
+	var b strings.Builder
+	names := c.visibleCommands()
+
+	b.WriteString("#compdef gator\n")
+	b.WriteString("# zsh completion for gator, load with: source <(gator completion zsh)\n")
+	b.WriteString("_gator() {\n")
+	b.WriteString("    local -a commands\n    commands=(\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "        %s\n", zshQuote(name+":"+c.Info[name].Description))
+	}
+	b.WriteString("    )\n")
+	b.WriteString("    if (( CURRENT == 2 )); then\n        _describe 'command' commands\n        return\n    fi\n")
+	b.WriteString("    local cmd=${words[2]}\n")
+	b.WriteString("    if [[ ${words[CURRENT-1]} == --output ]]; then\n        compadd -- json csv ndjson table\n        return\n    fi\n")
+
+	b.WriteString("    if [[ $PREFIX == -* ]]; then\n        case $cmd in\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "            %s) compadd -- %s ;;\n", name, strings.Join(c.flagNames(name), " "))
+	}
+	b.WriteString("        esac\n        return\n    fi\n")
+
+	b.WriteString("    (( CURRENT == 3 )) || return\n")
+	b.WriteString("    case $cmd in\n")
+	for _, name := range names {
+		info := c.Info[name]
+		switch {
+		case len(info.ArgWords) > 0:
+			fmt.Fprintf(&b, "        %s) compadd -- %s ;;\n", name, strings.Join(info.ArgWords, " "))
+		case info.Complete == CompleteFiles:
+			fmt.Fprintf(&b, "        %s) _files ;;\n", name)
+		case info.Complete != CompleteNone:
+			fmt.Fprintf(&b, "        %s) compadd -- ${(f)\"$(gator %s %s 2>/dev/null)\"} ;;\n", name, completeCommand, name)
+		}
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("}\n")
+	b.WriteString("compdef _gator gator\n")
+	return b.String()
+}
+
+func (c *Commands) fishCompletion() string {
+	var b strings.Builder
+	names := c.visibleCommands()
+
+	b.WriteString("# fish completion for gator, load with: gator completion fish | source\n")
+	b.WriteString("complete -c gator -f\n")
+	b.WriteString("complete -c gator -l output -x -a 'json csv ndjson table' -d 'Output format'\n")
+	b.WriteString("complete -c gator -l db -x -d 'Database url'\n")
+	for _, name := range names {
+		info := c.Info[name]
+		fmt.Fprintf(&b, "complete -c gator -n __fish_use_subcommand -a %s -d %s\n", name, fishQuote(info.Description))
+
+		seen := fmt.Sprintf("'__fish_seen_subcommand_from %s'", name)
+		for _, f := range info.Flags {
+			fmt.Fprintf(&b, "complete -c gator -n %s -l %s -d %s\n", seen, f.Name, fishQuote(f.Usage))
+		}
+		switch {
+		case len(info.ArgWords) > 0:
+			fmt.Fprintf(&b, "complete -c gator -n %s -a %s\n", seen, fishQuote(strings.Join(info.ArgWords, " ")))
+		case info.Complete == CompleteFiles:
+			fmt.Fprintf(&b, "complete -c gator -n %s -F\n", seen)
+		case info.Complete != CompleteNone:
+			fmt.Fprintf(&b, "complete -c gator -n %s -a '(gator %s %s 2>/dev/null)'\n", seen, completeCommand, name)
+		}
+	}
+	return b.String()
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestBashCompletionURLs runs the bash script the way readline would, with
+// COMP_WORDS split on the colon in "https:"
+func TestBashCompletionURLs(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	c := &Commands{}
+	c.Register("follow", nil, CommandInfo{Usage: "follow <url>", MinArgs: 1, MaxArgs: 1, Complete: CompleteFeeds})
+	c.Register("feeds", nil, CommandInfo{ArgWords: []string{"health", "enable"}})
+
+	tests := []struct {
+		line  string
+		words string
+		want  string
+	}{
+		{"gator fo", "gator fo", "follow"},
+		{"gator feeds h", "gator feeds h", "health"},
+		{"gator follow ", "gator follow ''", "https://example.com/feed https://example.org/rss"},
+		{"gator follow https://example.c", "gator follow https : //example.c", "//example.com/feed"},
+		{"gator follow https://example.com/feed x", "gator follow https : //example.com/feed x", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			script := c.bashCompletion() + `
+gator() { printf '%s\n' https://example.com/feed https://example.org/rss; }
+COMP_LINE='` + tt.line + `'
+COMP_POINT=${#COMP_LINE}
+COMP_WORDS=(` + tt.words + `)
+COMP_CWORD=$(( ${#COMP_WORDS[@]} - 1 ))
+_gator
+echo "${COMPREPLY[*]}"
+`
+			out, err := exec.Command(bash, "--norc", "--noprofile", "-c", script).CombinedOutput()
+			if err != nil {
+				t.Fatalf("bash: %v\n%s", err, out)
+			}
+			if got := strings.TrimSpace(string(out)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,22 +2,59 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
 )
 
 const configPath = "/.gatorconfig.json"
 
+// environment variables that override the config file
+const (
+	envConfig = "GATOR_CONFIG"
+	envDbURL  = "GATOR_DB_URL"
+)
+
 type Config struct {
 	DbURL           string `json:"db_url"`
 	CurrentUserName string `json:"current_user_name"`
+	// proves the login, checked against the sessions table
+	SessionToken string `json:"session_token,omitempty"`
+}
+
+// Load builds the config in layers: the file, then the environment, then
+// dbFlag (from --db) if it's set. The database url is checked before returning.
+func Load(dbFlag string) (Config, error) {
+	cfg, err := Read()
+	if err != nil {
+		// the file is optional if the url comes from somewhere else
+		if !errors.Is(err, fs.ErrNotExist) || (os.Getenv(envDbURL) == "" && dbFlag == "") {
+			return Config{}, err
+		}
+	}
+	if env := os.Getenv(envDbURL); env != "" {
+		cfg.DbURL = env
+	}
+	if dbFlag != "" {
+		cfg.DbURL = dbFlag
+	}
+	if err := validateDbURL(cfg.DbURL); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
 }
 
 func Read() (Config, error) {
-	homeDir, err := os.UserHomeDir()
+	jsonpath, err := path()
 	if err != nil {
 		return Config{}, err
 	}
-	jsonpath := homeDir + configPath
 
 	file, err := os.Open(jsonpath)
 	if err != nil {
@@ -27,28 +64,89 @@ func Read() (Config, error) {
 
 	var config Config
 	if err := json.NewDecoder(file).Decode(&config); err != nil {
-		return Config{}, err
+		return Config{}, fmt.Errorf("error reading %s: %v", jsonpath, err)
 	}
 	return config, nil
 }
 
-func write(cfg Config) error {
+// path is $GATOR_CONFIG if set, otherwise ~/.gatorconfig.json
+func path() (string, error) {
+	if p := os.Getenv(envConfig); p != "" {
+		return p, nil
+	}
 	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return homeDir + configPath, nil
+}
+
+// validateDbURL accepts what lib/pq does, a postgres:// url or a key=value
+// connection string, checking the syntax without connecting
+func validateDbURL(dbURL string) error {
+	if dbURL == "" {
+		return fmt.Errorf("no database url: set db_url in the config file, %s or --db", envDbURL)
+	}
+	if strings.Contains(dbURL, "://") {
+		// url.Parse errors quote the whole url, password and all, so leave them out
+		u, err := url.Parse(dbURL)
+		if err != nil {
+			return fmt.Errorf("invalid database url %q", redactDbURL(dbURL))
+		}
+		if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			return fmt.Errorf("invalid database url %q: must start with postgres:// or be a key=value connection string", redactDbURL(dbURL))
+		}
+	}
+	if _, err := pq.NewConnector(dbURL); err != nil {
+		return fmt.Errorf("invalid database url %q: %v", redactDbURL(dbURL), err)
+	}
+	return nil
+}
+
+// passwords in the userinfo of a url, its query, or a key=value string
+var (
+	urlPasswordRe   = regexp.MustCompile(`^([^:/?#]+://[^:@/?#]*:)[^/?#]*@`)
+	queryPasswordRe = regexp.MustCompile(`([?&]password=)[^&#]*`)
+	dsnPasswordRe   = regexp.MustCompile(`(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'?|\S*)`)
+)
+
+// redactDbURL hides any password in a database url, leaving the rest as given
+func redactDbURL(dbURL string) string {
+	if strings.Contains(dbURL, "://") {
+		dbURL = urlPasswordRe.ReplaceAllString(dbURL, "${1}xxxxx@")
+		return queryPasswordRe.ReplaceAllString(dbURL, "${1}xxxxx")
+	}
+	return dsnPasswordRe.ReplaceAllString(dbURL, "${1}xxxxx")
+}
+
+func write(cfg Config) error {
+	jsonpath, err := path()
 	if err != nil {
 		return err
 	}
-	jsonpath := homeDir + configPath
 
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(jsonpath, data, 0644)
-
+	// it holds a session token, so keep it private even if it already existed
+	if err := os.WriteFile(jsonpath, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(jsonpath, 0600)
 }
 
-func (c *Config) SetUser(userName string) error {
+// SetSession saves who is logged in and their session token
+func (c *Config) SetSession(userName, token string) error {
 	c.CurrentUserName = userName
-	return write(*c)
+	c.SessionToken = token
+	// write over what's in the file, so an overridden db url doesn't get saved
+	cfg, err := Read()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	cfg.CurrentUserName = userName
+	cfg.SessionToken = token
+	return write(cfg)
 }
@@ -0,0 +1,390 @@
+package config
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"html"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frankielb/gator/internal/database"
+)
+
+// the Fever API version clients are told we speak
+const feverAPIVersion = 3
+
+// feverPageSize is how many items Fever returns per request
+const feverPageSize = 50
+
+// HandlerFever turns the Fever sync API on or off for the current user
+func HandlerFever(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return cmd.UsageError("no fever subcommand given")
+	}
+	ctx := context.Background()
+	switch cmd.Args[0] {
+	case "enable":
+		password, err := promptPassword("Fever password: ")
+		if err != nil {
+			return err
+		}
+		if password == "" {
+			return fmt.Errorf("password can't be empty")
+		}
+		err = s.Db.SetFeverKey(ctx, database.SetFeverKeyParams{
+			UserID: user.ID,
+			ApiKey: feverKey(user.Name, password),
+		})
+		if err != nil {
+			return fmt.Errorf("error saving fever key: %v", err)
+		}
+		fmt.Printf("Fever API enabled for %s\n", user.Name)
+		fmt.Println("Point your reader at http://<serve address>/fever/ and log in with your user name and that password.")
+	case "disable":
+		n, err := s.Db.DeleteFeverKey(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("error removing fever key: %v", err)
+		}
+		if n == 0 {
+			fmt.Println("Fever API was not enabled")
+			return nil
+		}
+		fmt.Printf("Fever API disabled for %s\n", user.Name)
+	default:
+		return cmd.UsageError("unknown fever subcommand: %s", cmd.Args[0])
+	}
+	return nil
+}
+
+// feverKey is what Fever clients send as api_key: md5 of "name:password"
+func feverKey(name, password string) string {
+	sum := md5.Sum([]byte(name + ":" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+// fever serves the Fever API. It's a single endpoint: the query string says
+// what to return, the form carries the api_key and any mark request.
+func (a *api) fever(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid form: %v", err)
+		return
+	}
+	ctx := r.Context()
+	resp := map[string]any{
+		"api_version": feverAPIVersion,
+		"auth":        0,
+	}
+	user, err := a.s.Db.GetUserByFeverKey(ctx, strings.ToLower(r.FormValue("api_key")))
+	if err == sql.ErrNoRows {
+		writeFever(w, resp)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error finding user: %v", err)
+		return
+	}
+	resp["auth"] = 1
+
+	// marks go first so the lists below include them
+	if r.PostFormValue("mark") != "" {
+		if err := a.feverMark(ctx, user, r); err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+	}
+
+	feeds, err := a.s.Db.GetFeverFeeds(ctx, user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting feeds: %v", err)
+		return
+	}
+	var refreshed int64
+	for _, feed := range feeds {
+		if feed.LastFetchedAt.Valid {
+			refreshed = max(refreshed, feed.LastFetchedAt.Time.Unix())
+		}
+	}
+	resp["last_refreshed_on_time"] = refreshed
+
+	query := r.URL.Query()
+	if query.Has("groups") {
+		resp["groups"] = feverGroups(feeds)
+		resp["feeds_groups"] = feverFeedsGroups(feeds)
+	}
+	if query.Has("feeds") {
+		list := []map[string]any{}
+		for _, feed := range feeds {
+			var updated int64
+			if feed.LastFetchedAt.Valid {
+				updated = feed.LastFetchedAt.Time.Unix()
+			}
+			list = append(list, map[string]any{
+				"id":                   feed.Seq,
+				"favicon_id":           0,
+				"title":                feed.Name,
+				"url":                  feed.Url,
+				"site_url":             feed.SiteUrl.String,
+				"is_spark":             0,
+				"last_updated_on_time": updated,
+			})
+		}
+		resp["feeds"] = list
+		resp["feeds_groups"] = feverFeedsGroups(feeds)
+	}
+	// gator doesn't keep favicons or hot links, but clients expect the keys
+	if query.Has("favicons") {
+		resp["favicons"] = []any{}
+	}
+	if query.Has("links") {
+		resp["links"] = []any{}
+	}
+	if query.Has("items") {
+		items, total, err := a.feverItems(ctx, user, query.Get("since_id"), query.Get("max_id"), query.Get("with_ids"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		resp["items"] = items
+		resp["total_items"] = total
+	}
+	if query.Has("unread_item_ids") {
+		seqs, err := a.s.Db.GetUnreadPostSeqs(ctx, user.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "error getting unread items: %v", err)
+			return
+		}
+		resp["unread_item_ids"] = joinIDs(seqs)
+	}
+	if query.Has("saved_item_ids") {
+		seqs, err := a.s.Db.GetStarredPostSeqs(ctx, user.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "error getting saved items: %v", err)
+			return
+		}
+		resp["saved_item_ids"] = joinIDs(seqs)
+	}
+	writeFever(w, resp)
+}
+
+func (a *api) feverItems(ctx context.Context, user database.User, sinceID, maxID, withIDs string) ([]map[string]any, int64, error) {
+	params := database.GetFeverItemsParams{
+		UserID:   user.ID,
+		PageSize: feverPageSize,
+	}
+	if sinceID != "" {
+		n, err := strconv.ParseInt(sinceID, 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid since_id '%v'", sinceID)
+		}
+		params.SinceID = sql.NullInt64{Int64: n, Valid: true}
+	}
+	if maxID != "" {
+		n, err := strconv.ParseInt(maxID, 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid max_id '%v'", maxID)
+		}
+		params.MaxID = sql.NullInt64{Int64: n, Valid: true}
+	}
+	if withIDs != "" {
+		params.WithIds = []int64{}
+		for _, id := range strings.Split(withIDs, ",") {
+			n, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid id '%v' in with_ids", id)
+			}
+			params.WithIds = append(params.WithIds, n)
+		}
+	}
+
+	posts, err := a.s.Db.GetFeverItems(ctx, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting items: %v", err)
+	}
+	total, err := a.s.Db.CountFeverItems(ctx, user.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting items: %v", err)
+	}
+	items := []map[string]any{}
+	for _, post := range posts {
+		items = append(items, map[string]any{
+			"id":              post.Seq,
+			"feed_id":         post.FeedSeq,
+			"title":           html.UnescapeString(post.Title),
+			"author":          "",
+			"html":            html.UnescapeString(post.Description.String),
+			"url":             post.Url,
+			"is_saved":        feverBool(post.IsSaved),
+			"is_read":         feverBool(post.IsRead),
+			"created_on_time": post.PostedAt.Unix(),
+		})
+	}
+	return items, total, nil
+}
+
+// feverMark handles mark=item|feed|group with as=read|unread|saved|unsaved
+func (a *api) feverMark(ctx context.Context, user database.User, r *http.Request) error {
+	mark, as := r.PostFormValue("mark"), r.PostFormValue("as")
+	id, err := strconv.ParseInt(r.PostFormValue("id"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid id '%v'", r.PostFormValue("id"))
+	}
+	var before sql.NullTime
+	if value := r.PostFormValue("before"); value != "" {
+		unix, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid before '%v'", value)
+		}
+		before = sql.NullTime{Time: time.Unix(unix, 0).UTC(), Valid: true}
+	}
+
+	switch mark {
+	case "item":
+		post, err := a.s.Db.GetPostBySeq(ctx, id)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no item with id %d", id)
+		}
+		if err != nil {
+			return err
+		}
+		switch as {
+		case "read":
+			return a.s.Db.MarkPostRead(ctx, database.MarkPostReadParams{UserID: user.ID, PostID: post.ID})
+		case "unread":
+			return a.s.Db.MarkPostUnread(ctx, database.MarkPostUnreadParams{UserID: user.ID, PostID: post.ID})
+		case "saved":
+			return a.s.Db.StarPost(ctx, database.StarPostParams{UserID: user.ID, PostID: post.ID})
+		case "unsaved":
+			_, err := a.s.Db.UnstarPost(ctx, database.UnstarPostParams{UserID: user.ID, PostID: post.ID})
+			return err
+		}
+		return fmt.Errorf("can't mark an item as '%v'", as)
+	case "feed":
+		if as != "read" {
+			return fmt.Errorf("can't mark a feed as '%v'", as)
+		}
+		feed, err := a.s.Db.GetFeedBySeq(ctx, id)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no feed with id %d", id)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = a.s.Db.MarkAllPostsRead(ctx, database.MarkAllPostsReadParams{
+			UserID:  user.ID,
+			FeedUrl: nullString(feed.Url),
+			Before:  before,
+		})
+		return err
+	case "group":
+		if as != "read" {
+			return fmt.Errorf("can't mark a group as '%v'", as)
+		}
+		// group 0 is every feed, -1 is sparks which gator doesn't have
+		if id == 0 {
+			_, err := a.s.Db.MarkAllPostsRead(ctx, database.MarkAllPostsReadParams{
+				UserID: user.ID,
+				Before: before,
+			})
+			return err
+		}
+		feeds, err := a.s.Db.GetFeverFeeds(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		for _, feed := range feeds {
+			if !feed.Category.Valid || feverGroupID(feed.Category.String) != id {
+				continue
+			}
+			_, err := a.s.Db.MarkAllPostsRead(ctx, database.MarkAllPostsReadParams{
+				UserID:  user.ID,
+				FeedUrl: nullString(feed.Url),
+				Before:  before,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("can't mark '%v'", mark)
+}
+
+// feverGroupID gives a follow category a stable integer id. Groups only exist
+// as category names, so there's no table to number them.
+func feverGroupID(category string) int64 {
+	return int64(crc32.ChecksumIEEE([]byte(category))&0x7fffffff) + 1
+}
+
+func feverGroups(feeds []database.GetFeverFeedsRow) []map[string]any {
+	seen := make(map[string]bool)
+	var categories []string
+	for _, feed := range feeds {
+		if feed.Category.Valid && !seen[feed.Category.String] {
+			seen[feed.Category.String] = true
+			categories = append(categories, feed.Category.String)
+		}
+	}
+	sort.Strings(categories)
+	groups := []map[string]any{}
+	for _, category := range categories {
+		groups = append(groups, map[string]any{
+			"id":    feverGroupID(category),
+			"title": category,
+		})
+	}
+	return groups
+}
+
+func feverFeedsGroups(feeds []database.GetFeverFeedsRow) []map[string]any {
+	members := make(map[string][]int64)
+	for _, feed := range feeds {
+		if feed.Category.Valid {
+			members[feed.Category.String] = append(members[feed.Category.String], feed.Seq)
+		}
+	}
+	categories := make([]string, 0, len(members))
+	for category := range members {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	feedsGroups := []map[string]any{}
+	for _, category := range categories {
+		feedsGroups = append(feedsGroups, map[string]any{
+			"group_id": feverGroupID(category),
+			"feed_ids": joinIDs(members[category]),
+		})
+	}
+	return feedsGroups
+}
+
+func joinIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func feverBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func writeFever(w http.ResponseWriter, resp map[string]any) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error encoding response: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
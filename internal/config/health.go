@@ -0,0 +1,104 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/frankielb/gator/internal/database"
+	"github.com/frankielb/gator/internal/rss"
+)
+
+// recordHealth saves the outcome of a fetch on the feed, updating it in place so
+// the scheduler can back off. maxFailures of 0 never disables a feed.
+func recordHealth(ctx context.Context, s *State, feed *database.Feed, fetchErr error, maxFailures int) error {
+	var status sql.NullInt32
+	var httpErr *rss.HTTPError
+	switch {
+	case fetchErr == nil:
+		status = sql.NullInt32{Int32: http.StatusOK, Valid: true}
+	case errors.Is(fetchErr, rss.ErrNotModified):
+		status = sql.NullInt32{Int32: http.StatusNotModified, Valid: true}
+	case errors.As(fetchErr, &httpErr):
+		status = sql.NullInt32{Int32: int32(httpErr.StatusCode), Valid: true}
+	}
+
+	if fetchErr == nil || errors.Is(fetchErr, rss.ErrNotModified) {
+		if err := s.Db.RecordFeedSuccess(ctx, database.RecordFeedSuccessParams{
+			ID:         feed.ID,
+			LastStatus: status,
+		}); err != nil {
+			return err
+		}
+		feed.ConsecutiveFailures = 0
+		feed.LastStatus = status
+		return nil
+	}
+
+	updated, err := s.Db.RecordFeedFailure(ctx, database.RecordFeedFailureParams{
+		ID:          feed.ID,
+		LastError:   nullString(fetchErr.Error()),
+		LastStatus:  status,
+		MaxFailures: int32(maxFailures),
+	})
+	if err != nil {
+		return err
+	}
+	if updated.Disabled && !feed.Disabled {
+		fmt.Printf("Disabled feed %s after %d failures in a row\n", updated.Name, updated.ConsecutiveFailures)
+	}
+	*feed = updated
+	return nil
+}
+
+func handlerFeedsHealth(s *State, cmd Command) error {
+	feeds, err := s.Db.GetUnhealthyFeeds(context.Background())
+	if err != nil {
+		return fmt.Errorf("error getting feed health: %v", err)
+	}
+	if s.Structured() {
+		records := Records{Fields: []string{"name", "url", "disabled", "consecutive_failures", "last_status", "last_error", "last_error_at"}}
+		for _, feed := range feeds {
+			records.Add(feed.Name, feed.Url, feed.Disabled, feed.ConsecutiveFailures, feed.LastStatus, feed.LastError, feed.LastErrorAt)
+		}
+		return s.Render(records)
+	}
+	if len(feeds) == 0 {
+		fmt.Println("All feeds are healthy.")
+		return nil
+	}
+	for _, feed := range feeds {
+		state := "failing"
+		if feed.Disabled {
+			state = "disabled"
+		}
+		fmt.Printf("Feed: %v (%s)\n -URL: %v\n", feed.Name, state, feed.Url)
+		fmt.Printf(" -Failures in a row: %d\n", feed.ConsecutiveFailures)
+		if feed.LastStatus.Valid {
+			fmt.Printf(" -Last status: %d\n", feed.LastStatus.Int32)
+		}
+		if feed.LastError.Valid {
+			fmt.Printf(" -Last error: %v\n -At: %v\n", feed.LastError.String, feed.LastErrorAt.Time)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func handlerFeedsEnable(s *State, cmd Command) error {
+	if len(cmd.Args) < 2 {
+		return cmd.UsageError("no url given")
+	}
+	url := cmd.Args[1]
+	n, err := s.Db.EnableFeed(context.Background(), url)
+	if err != nil {
+		return fmt.Errorf("error enabling feed: %v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no feed with url %s", url)
+	}
+	fmt.Printf("Feed %s enabled\n", url)
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/frankielb/gator/internal/migrate"
+	"github.com/frankielb/gator/sql/schema"
+)
+
+// HandlerMigrate runs the embedded schema migrations against the configured database
+func HandlerMigrate(s *State, cmd Command) error {
+	if len(cmd.Args) == 0 {
+		return cmd.UsageError("no migrate subcommand given")
+	}
+	migrator, err := migrate.New(s.Conn, schema.FS)
+	if err != nil {
+		return fmt.Errorf("error loading migrations: %v", err)
+	}
+	ctx := context.Background()
+
+	switch cmd.Args[0] {
+	case "up":
+		target := int64(cmd.Int("to"))
+		if target < 0 {
+			target = migrator.Latest()
+		}
+		ran, err := migrator.Up(ctx, target)
+		for _, m := range ran {
+			fmt.Printf("Applied %s\n", m.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "down":
+		target := int64(cmd.Int("to"))
+		if target < 0 {
+			target, err = migrator.Previous(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		ran, err := migrator.Down(ctx, target)
+		for _, m := range ran {
+			fmt.Printf("Rolled back %s\n", m.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		return migrateStatus(ctx, s, migrator)
+	case "version":
+	default:
+		return cmd.UsageError("unknown migrate subcommand: %s", cmd.Args[0])
+	}
+
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Database is at version %d (latest is %d)\n", version, migrator.Latest())
+	return nil
+}
+
+func migrateStatus(ctx context.Context, s *State, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if s.Structured() {
+		records := Records{Fields: []string{"version", "name", "applied", "applied_at"}}
+		for _, st := range statuses {
+			records.Add(st.Version, st.Name, st.AppliedAt.Valid, st.AppliedAt)
+		}
+		return s.Render(records)
+	}
+	for _, st := range statuses {
+		if st.AppliedAt.Valid {
+			fmt.Printf("  %-24s applied %v\n", st.Name, st.AppliedAt.Time.Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Printf("  %-24s pending\n", st.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/frankielb/gator/internal/database"
+	"github.com/frankielb/gator/internal/opml"
+	"github.com/google/uuid"
+)
+
+func HandlerImport(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return cmd.UsageError("no opml file given")
+	}
+	file, err := os.Open(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("error opening opml file: %v", err)
+	}
+	defer file.Close()
+
+	doc, err := opml.Parse(file)
+	if err != nil {
+		return fmt.Errorf("error parsing opml file: %v", err)
+	}
+
+	var added, followed, skipped, failed int
+	for _, entry := range doc.Entries() {
+		result, err := importEntry(s, user, entry)
+		if err != nil {
+			failed++
+			fmt.Printf("failed:   %s (%s): %v\n", entry.Title, entry.XMLURL, err)
+			continue
+		}
+		switch result {
+		case "added":
+			added++
+		case "followed":
+			followed++
+		case "skipped":
+			skipped++
+		}
+		fmt.Printf("%-9s %s (%s)\n", result+":", entry.Title, entry.XMLURL)
+	}
+	fmt.Printf("\nImported %s: %d added, %d followed, %d skipped, %d failed\n",
+		cmd.Args[0], added, followed, skipped, failed)
+	return nil
+}
+
+// importEntry creates the feed if it's new and follows it, reporting which it did
+func importEntry(s *State, user database.User, entry opml.Entry) (string, error) {
+	ctx := context.Background()
+	now := time.Now()
+	result := "followed"
+
+	feed, err := s.Db.GetFeedByURL(ctx, entry.XMLURL)
+	if err == sql.ErrNoRows {
+		name := entry.Title
+		if name == "" {
+			name = entry.XMLURL
+		}
+		feed, err = s.Db.CreateFeed(ctx, database.CreateFeedParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Name:      name,
+			Url:       entry.XMLURL,
+			UserID:    user.ID,
+		})
+		if err != nil {
+			return "", fmt.Errorf("error creating feed: %v", err)
+		}
+		if entry.HTMLURL != "" {
+			if err := s.Db.SetFeedSiteURL(ctx, database.SetFeedSiteURLParams{
+				ID:      feed.ID,
+				SiteUrl: nullString(entry.HTMLURL),
+			}); err != nil {
+				return "", fmt.Errorf("error saving site url: %v", err)
+			}
+		}
+		result = "added"
+	} else if err != nil {
+		return "", fmt.Errorf("error finding feed: %v", err)
+	} else {
+		_, err := s.Db.GetFeedFollow(ctx, database.GetFeedFollowParams{
+			UserID: user.ID,
+			FeedID: feed.ID,
+		})
+		if err == nil {
+			return "skipped", nil
+		}
+		if err != sql.ErrNoRows {
+			return "", fmt.Errorf("error checking follow: %v", err)
+		}
+	}
+
+	_, err = s.Db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+		Category:  nullString(entry.Category),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error following feed: %v", err)
+	}
+	return result, nil
+}
+
+func HandlerExport(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return cmd.UsageError("no export format given")
+	}
+	switch cmd.Args[0] {
+	case "opml":
+		return handlerExportOPML(s, cmd, user)
+	case "feed":
+		return handlerExportFeed(s, cmd, user)
+	default:
+		return cmd.UsageError("unknown export format: %s", cmd.Args[0])
+	}
+}
+
+// exportUser is the --user to export, which needs admin rights unless it's
+// the logged in user
+func exportUser(ctx context.Context, s *State, cmd Command, user database.User) (database.User, error) {
+	name := cmd.String("user")
+	if name == "" || name == user.Name {
+		return user, nil
+	}
+	if err := requireAdmin(ctx, s, user); err != nil {
+		return database.User{}, err
+	}
+	other, err := s.Db.GetUser(ctx, name)
+	if err != nil {
+		return database.User{}, fmt.Errorf("error finding user '%v': %v", name, err)
+	}
+	return other, nil
+}
+
+// handlerExportOPML writes the subscriptions of a user, or every feed, to stdout
+func handlerExportOPML(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+	var title string
+	var entries []opml.Entry
+	if cmd.Bool("all") {
+		feeds, err := s.Db.GetFeeds(ctx)
+		if err != nil {
+			return fmt.Errorf("error getting feeds: %v", err)
+		}
+		title = "gator feeds"
+		for _, feed := range feeds {
+			entries = append(entries, opml.Entry{
+				Title:   feed.Name,
+				XMLURL:  feed.Url,
+				HTMLURL: feed.SiteUrl.String,
+			})
+		}
+	} else {
+		user, err := exportUser(ctx, s, cmd, user)
+		if err != nil {
+			return err
+		}
+		follows, err := s.Db.GetFeedFollowsUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("error getting following: %v", err)
+		}
+		title = fmt.Sprintf("gator subscriptions for %s", user.Name)
+		for _, follow := range follows {
+			entries = append(entries, opml.Entry{
+				Title:    follow.FeedName,
+				XMLURL:   follow.FeedUrl,
+				HTMLURL:  follow.FeedSiteUrl.String,
+				Category: follow.Category.String,
+			})
+		}
+	}
+
+	return opml.Build(title, entries).Write(os.Stdout)
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	OutputTable  = "table"
+	OutputJSON   = "json"
+	OutputCSV    = "csv"
+	OutputNDJSON = "ndjson"
+)
+
+// Records is what a listing command produces: field names that scripts can rely
+// on, and one row of values per record in the same order
+type Records struct {
+	Fields []string
+	Rows   [][]any
+}
+
+func (r *Records) Add(values ...any) {
+	r.Rows = append(r.Rows, values)
+}
+
+func validOutput(format string) bool {
+	switch format {
+	case OutputTable, OutputJSON, OutputCSV, OutputNDJSON:
+		return true
+	}
+	return false
+}
+
+// Structured reports whether handlers should hand their records to Render
+// rather than print their usual text
+func (s *State) Structured() bool {
+	return s.Output != ""
+}
+
+// Render writes records to stdout in the state's output format
+func (s *State) Render(records Records) error {
+	return renderRecords(os.Stdout, s.Output, records)
+}
+
+func renderRecords(w io.Writer, format string, records Records) error {
+	switch format {
+	case OutputJSON:
+		var buf bytes.Buffer
+		buf.WriteString("[")
+		for i, row := range records.Rows {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			if err := writeObject(&buf, records.Fields, row); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+		var out bytes.Buffer
+		if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+			return err
+		}
+		out.WriteString("\n")
+		_, err := out.WriteTo(w)
+		return err
+	case OutputNDJSON:
+		for _, row := range records.Rows {
+			var buf bytes.Buffer
+			if err := writeObject(&buf, records.Fields, row); err != nil {
+				return err
+			}
+			buf.WriteString("\n")
+			if _, err := buf.WriteTo(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OutputCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(records.Fields); err != nil {
+			return err
+		}
+		for _, row := range records.Rows {
+			line := make([]string, len(row))
+			for i, value := range row {
+				line[i] = csvValue(value)
+			}
+			if err := writer.Write(line); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case OutputTable:
+		return writeTable(w, records)
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// tableCellWidth stops descriptions and errors from stretching a table off screen
+const tableCellWidth = 60
+
+// writeTable lines records up in columns under their field names
+func writeTable(w io.Writer, records Records) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(records.Fields, "\t")))
+	for _, row := range records.Rows {
+		cells := make([]string, len(row))
+		for i, value := range row {
+			cells[i] = tableCell(csvValue(value))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// tableCell keeps a value on one line and shortens it to tableCellWidth
+func tableCell(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return "-"
+	}
+	if runes := []rune(value); len(runes) > tableCellWidth {
+		return string(runes[:tableCellWidth-1]) + "…"
+	}
+	return value
+}
+
+// writeObject keeps the fields in order, which a map wouldn't
+func writeObject(buf *bytes.Buffer, fields []string, row []any) error {
+	buf.WriteString("{")
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, err := json.Marshal(field)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(plainValue(row[i]))
+		if err != nil {
+			return err
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+// plainValue unwraps sql null types so they encode as the value or null
+func plainValue(value any) any {
+	switch v := value.(type) {
+	case sql.NullString:
+		if v.Valid {
+			return v.String
+		}
+		return nil
+	case sql.NullTime:
+		if v.Valid {
+			return v.Time.UTC().Format(time.RFC3339)
+		}
+		return nil
+	case sql.NullInt32:
+		if v.Valid {
+			return v.Int32
+		}
+		return nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return value
+}
+
+// unescapeNull undoes the escaping titles and descriptions are stored with, so
+// every output format and the API give the text as the feed had it
+func unescapeNull(s sql.NullString) sql.NullString {
+	if s.Valid {
+		s.String = html.UnescapeString(s.String)
+	}
+	return s
+}
+
+func csvValue(value any) string {
+	switch v := plainValue(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
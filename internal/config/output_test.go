@@ -0,0 +1,41 @@
+package config
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+)
+
+func TestRenderTable(t *testing.T) {
+	records := Records{Fields: []string{"name", "url", "category"}}
+	records.Add("Example", "https://example.com/feed", sql.NullString{String: "news", Valid: true})
+	records.Add("A longer name", "https://example.org/rss", sql.NullString{})
+
+	var buf bytes.Buffer
+	if err := renderRecords(&buf, OutputTable, records); err != nil {
+		t.Fatalf("renderRecords: %v", err)
+	}
+	want := "NAME           URL                       CATEGORY\n" +
+		"Example        https://example.com/feed  news\n" +
+		"A longer name  https://example.org/rss   -\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTableCell(t *testing.T) {
+	long := ""
+	for i := 0; i < 10; i++ {
+		long += "0123456789"
+	}
+	tests := map[string]string{
+		"":                 "-",
+		"two\nlines  here": "two lines here",
+		long:               long[:tableCellWidth-1] + "…",
+	}
+	for in, want := range tests {
+		if got := tableCell(in); got != want {
+			t.Errorf("tableCell(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/frankielb/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// resolvePost finds a post by the ID shown in browse, or by its URL
+func resolvePost(s *State, ref string) (database.Post, error) {
+	var post database.Post
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		post, err = s.Db.GetPost(context.Background(), id)
+	} else {
+		post, err = s.Db.GetPostByURL(context.Background(), ref)
+	}
+	if err == sql.ErrNoRows {
+		return post, fmt.Errorf("no post found for '%v'", ref)
+	}
+	if err != nil {
+		return post, fmt.Errorf("error finding post: %v", err)
+	}
+	return post, nil
+}
+
+func HandlerRead(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return cmd.UsageError("no post given")
+	}
+	post, err := resolvePost(s, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	err = s.Db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error marking post read: %v", err)
+	}
+	fmt.Printf("Marked read: %s\n", post.Title)
+	return nil
+}
+
+func HandlerUnread(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return cmd.UsageError("no post given")
+	}
+	post, err := resolvePost(s, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	err = s.Db.MarkPostUnread(context.Background(), database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error marking post unread: %v", err)
+	}
+	fmt.Printf("Marked unread: %s\n", post.Title)
+	return nil
+}
+
+func HandlerMarkAll(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 || cmd.Args[0] != "read" {
+		return cmd.UsageError("only 'markall read' is supported")
+	}
+
+	params := database.MarkAllPostsReadParams{
+		UserID:  user.ID,
+		FeedUrl: nullString(cmd.String("feed")),
+	}
+	if before := cmd.String("before"); before != "" {
+		t, err := parseDateFlag(before)
+		if err != nil {
+			return err
+		}
+		params.Before = sql.NullTime{Time: t, Valid: true}
+	}
+	n, err := s.Db.MarkAllPostsRead(context.Background(), params)
+	if err != nil {
+		return fmt.Errorf("error marking posts read: %v", err)
+	}
+	fmt.Printf("Marked %d posts read\n", n)
+	return nil
+}
+
+// parseDateFlag accepts a plain date or a full RFC3339 timestamp
+func parseDateFlag(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%v', use YYYY-MM-DD or RFC3339", value)
+	}
+	return t, nil
+}
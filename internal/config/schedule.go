@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/frankielb/gator/internal/database"
+)
+
+// maxScheduleInterval keeps next_fetch_at's offset in range of the int32 seconds
+// ScheduleFeedFetch takes, even with a huge --max-interval
+const maxScheduleInterval = 365 * 24 * time.Hour
+
+// how many recent posts are used to estimate a feed's posting frequency
+const recentPostSample = 20
+
+// Scheduler works out when a feed should next be fetched from how often it posts
+type Scheduler struct {
+	MinInterval time.Duration
+	MaxInterval time.Duration
+}
+
+// Interval is half the average gap between posts, counting the quiet time since
+// the newest one, so a feed that stops posting slowly drifts to the max.
+func (sch Scheduler) Interval(postTimes []time.Time, now time.Time) time.Duration {
+	if len(postTimes) == 0 {
+		return sch.MinInterval
+	}
+	oldest := postTimes[0]
+	for _, t := range postTimes {
+		if t.Before(oldest) {
+			oldest = t
+		}
+	}
+	interval := now.Sub(oldest) / time.Duration(len(postTimes)) / 2
+	return sch.clamp(interval)
+}
+
+func (sch Scheduler) clamp(interval time.Duration) time.Duration {
+	if interval < sch.MinInterval {
+		return sch.MinInterval
+	}
+	if interval > sch.MaxInterval {
+		return sch.MaxInterval
+	}
+	return interval
+}
+
+// next applies the publisher's hints on top of the posting interval: ttl,
+// failure backoff and Retry-After only ever lengthen it, up to the max, then
+// skipHours/skipDays (GMT) push it on.
+func (sch Scheduler) next(postTimes []time.Time, now time.Time, feed database.Feed, retryAfter time.Duration) time.Duration {
+	interval := sch.Interval(postTimes, now)
+	if feed.TtlMinutes.Valid {
+		if ttl := time.Duration(feed.TtlMinutes.Int32) * time.Minute; ttl > interval {
+			interval = ttl
+		}
+	}
+	if backoff := sch.backoff(feed.ConsecutiveFailures); backoff > interval {
+		interval = backoff
+	}
+	if retryAfter > interval {
+		interval = retryAfter
+	}
+	// publishers can ask for anything, don't let them stop us polling
+	interval = min(interval, sch.MaxInterval)
+
+	at := now.Add(interval).UTC()
+	// a week of hours is enough to get past any combination of skips
+	for i := 0; i < 24*7 && isSkipped(at, feed.SkipHours, feed.SkipDays); i++ {
+		at = at.Truncate(time.Hour).Add(time.Hour)
+	}
+	return at.Sub(now)
+}
+
+// backoff doubles the min interval for each failure in a row, up to the max
+func (sch Scheduler) backoff(failures int32) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	if failures > 30 {
+		failures = 30
+	}
+	backoff := sch.MinInterval << failures
+	if backoff <= 0 || backoff > sch.MaxInterval {
+		return sch.MaxInterval
+	}
+	return backoff
+}
+
+func isSkipped(t time.Time, skipHours, skipDays int32) bool {
+	return skipHours&(1<<t.Hour()) != 0 || skipDays&(1<<int(t.Weekday())) != 0
+}
+
+// hoursMask packs skipHours into a bit per hour
+func hoursMask(hours []int) int32 {
+	var mask int32
+	for _, h := range hours {
+		if h >= 0 && h < 24 {
+			mask |= 1 << h
+		}
+	}
+	return mask
+}
+
+// daysMask packs skipDays into a bit per weekday, Sunday first
+func daysMask(days []time.Weekday) int32 {
+	var mask int32
+	for _, d := range days {
+		mask |= 1 << int(d)
+	}
+	return mask
+}
+
+// scheduleFeed sets next_fetch_at from the feed's recent posts and hints
+func (sch Scheduler) scheduleFeed(ctx context.Context, s *State, feed database.Feed, retryAfter time.Duration) error {
+	postTimes, err := s.Db.GetRecentPostTimes(ctx, database.GetRecentPostTimesParams{
+		FeedID: feed.ID,
+		Limit:  recentPostSample,
+	})
+	if err != nil {
+		return fmt.Errorf("error getting recent posts: %v", err)
+	}
+	interval := min(sch.next(postTimes, time.Now(), feed, retryAfter), maxScheduleInterval)
+	return s.Db.ScheduleFeedFetch(ctx, database.ScheduleFeedFetchParams{
+		ID:              feed.ID,
+		IntervalSeconds: int32(interval / time.Second),
+	})
+}
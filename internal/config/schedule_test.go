@@ -0,0 +1,42 @@
+package config
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/frankielb/gator/internal/database"
+)
+
+func TestSchedulerNextCapsPublisherHints(t *testing.T) {
+	sch := Scheduler{MinInterval: time.Minute, MaxInterval: 24 * time.Hour}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		feed       database.Feed
+		retryAfter time.Duration
+	}{
+		"huge ttl": {
+			feed: database.Feed{TtlMinutes: sql.NullInt32{Int32: 99999999, Valid: true}},
+		},
+		"huge retry-after": {
+			retryAfter: 1 << 62,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := sch.next(nil, now, tt.feed, tt.retryAfter)
+			if got != sch.MaxInterval {
+				t.Errorf("got %v, want the max interval %v", got, sch.MaxInterval)
+			}
+		})
+	}
+}
+
+func TestSchedulerNextKeepsSmallTTL(t *testing.T) {
+	sch := Scheduler{MinInterval: time.Minute, MaxInterval: 24 * time.Hour}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	feed := database.Feed{TtlMinutes: sql.NullInt32{Int32: 60, Valid: true}}
+	if got := sch.next(nil, now, feed, 0); got != time.Hour {
+		t.Errorf("got %v, want 1h from the ttl", got)
+	}
+}
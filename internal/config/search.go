@@ -0,0 +1,63 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/frankielb/gator/internal/database"
+)
+
+func HandlerSearch(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return cmd.UsageError("no query given")
+	}
+	// let the query be unquoted on the command line
+	query := strings.Join(cmd.Args, " ")
+
+	params := database.SearchPostsForUserParams{
+		Query:    query,
+		UserID:   user.ID,
+		Feed:     nullString(cmd.String("feed")),
+		PageSize: int32(cmd.Int("limit")),
+	}
+	if since := cmd.String("since"); since != "" {
+		t, err := parseDateFlag(since)
+		if err != nil {
+			return err
+		}
+		params.Since = sql.NullTime{Time: t, Valid: true}
+	}
+	results, err := s.Db.SearchPostsForUser(context.Background(), params)
+	if err != nil {
+		return fmt.Errorf("error searching posts: %v", err)
+	}
+	if s.Structured() {
+		records := Records{Fields: []string{"id", "title", "url", "feed_name", "published_at", "rank", "snippet"}}
+		for _, result := range results {
+			records.Add(result.ID, html.UnescapeString(result.Title), result.Url, result.FeedName,
+				result.PublishedAt, result.Rank, html.UnescapeString(result.Snippet))
+		}
+		return s.Render(records)
+	}
+	if len(results) == 0 {
+		fmt.Printf("no posts match '%s'\n", query)
+		return nil
+	}
+	fmt.Printf("Found %d posts matching '%s':\n\n", len(results), query)
+	for i, result := range results {
+		fmt.Printf("%d. %s\n", i+1, html.UnescapeString(result.Title))
+		fmt.Printf("   ID: %s\n", result.ID)
+		fmt.Printf("   URL: %s\n", result.Url)
+		fmt.Printf("   Feed: %s\n", result.FeedName)
+		// descriptions are stored escaped, so unescape the snippet for display
+		fmt.Printf("   %s\n", html.UnescapeString(result.Snippet))
+		if result.PublishedAt.Valid {
+			fmt.Printf("   Published: %s\n", result.PublishedAt.Time)
+		}
+		fmt.Println()
+	}
+	return nil
+}
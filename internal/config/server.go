@@ -0,0 +1,534 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/frankielb/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// HandlerServe runs the JSON API until interrupted
+func HandlerServe(s *State, cmd Command) error {
+	addr := cmd.String("addr")
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           NewAPI(s),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Serving the API on %s\n", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error serving: %v", err)
+	}
+	fmt.Println("Server stopped")
+	return nil
+}
+
+type api struct {
+	s *State
+}
+
+// NewAPI routes the REST endpoints. Requests need an "Authorization: Bearer <token>"
+// header with a token from 'gator token create'. Responses use the same field names
+// as --output json.
+func NewAPI(s *State) http.Handler {
+	a := &api{s: s}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users", a.withScope(ScopeAdmin, a.listUsers))
+	mux.HandleFunc("POST /api/users", a.withScope(ScopeAdmin, a.createUser))
+	mux.HandleFunc("GET /api/users/{name}", a.withUser(ScopeRead, a.getUser))
+	mux.HandleFunc("GET /api/feeds", a.withScope(ScopeRead, a.listFeeds))
+	mux.HandleFunc("POST /api/users/{name}/feeds", a.withUser(ScopeWrite, a.createFeed))
+	mux.HandleFunc("GET /api/users/{name}/follows", a.withUser(ScopeRead, a.listFollows))
+	mux.HandleFunc("POST /api/users/{name}/follows", a.withUser(ScopeWrite, a.follow))
+	mux.HandleFunc("DELETE /api/users/{name}/follows", a.withUser(ScopeWrite, a.unfollow))
+	mux.HandleFunc("GET /api/users/{name}/posts", a.withUser(ScopeRead, a.browse))
+	// feed readers can't set headers, so the feed also takes ?token=
+	mux.HandleFunc("GET /api/users/{name}/feed", queryToken(a.withUser(ScopeRead, a.feed)))
+	mux.HandleFunc("PUT /api/users/{name}/posts/{id}/read", a.withUser(ScopeWrite, a.markRead))
+	mux.HandleFunc("DELETE /api/users/{name}/posts/{id}/read", a.withUser(ScopeWrite, a.markUnread))
+	// sync clients, authenticated with keys from 'gator fever enable'
+	mux.HandleFunc("/fever/", a.fever)
+	return mux
+}
+
+// authorize checks the request's API token has scope, answering 401 or 403 itself
+func (a *api) authorize(w http.ResponseWriter, r *http.Request, scope string) (database.GetAPITokenUserRow, bool) {
+	token, ok := bearerToken(r.Header.Get("Authorization"))
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gator"`)
+		writeError(w, http.StatusUnauthorized, "missing API token")
+		return database.GetAPITokenUserRow{}, false
+	}
+	row, err := tokenUser(r.Context(), a.s, token, scope)
+	switch {
+	case err == errTokenInvalid:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gator", error="invalid_token"`)
+		writeError(w, http.StatusUnauthorized, "%v", err)
+		return row, false
+	case err == errTokenScope:
+		writeError(w, http.StatusForbidden, "this needs a token with %s scope", scope)
+		return row, false
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return row, false
+	}
+	return row, true
+}
+
+// withScope only runs handler for tokens with scope
+func (a *api) withScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := a.authorize(w, r, scope); !ok {
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// withUser looks up the {name} in the path, the API's version of MiddlewareLoggedIn.
+// Tokens only work on their own user's paths unless they're admin.
+func (a *api) withUser(scope string, handler func(w http.ResponseWriter, r *http.Request, user database.User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		row, ok := a.authorize(w, r, scope)
+		if !ok {
+			return
+		}
+		name := r.PathValue("name")
+		if name == row.User.Name {
+			handler(w, r, row.User)
+			return
+		}
+		if !scopeAllows(tokenScope(row), ScopeAdmin) {
+			writeError(w, http.StatusForbidden, "this token can only be used for user '%v'", row.User.Name)
+			return
+		}
+		user, err := a.s.Db.GetUser(r.Context(), name)
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "user '%v' does not exist", name)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "error finding user: %v", err)
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+// queryToken accepts ?token= in place of the Authorization header
+func queryToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		handler(w, r)
+	}
+}
+
+// bearerToken is the token from an "Authorization: Bearer <token>" header
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func (a *api) listUsers(w http.ResponseWriter, r *http.Request) {
+	names, err := a.s.Db.GetUsers(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting users: %v", err)
+		return
+	}
+	records := Records{Fields: []string{"name"}}
+	for _, name := range names {
+		records.Add(name)
+	}
+	writeRecords(w, http.StatusOK, records)
+}
+
+func (a *api) createUser(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+	if body.Name == "" || body.Password == "" {
+		writeError(w, http.StatusBadRequest, "name and password are required")
+		return
+	}
+	hash, err := hashPassword(body.Password)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	now := time.Now()
+	user, err := a.s.Db.CreateUser(r.Context(), database.CreateUserParams{
+		ID:           uuid.New(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Name:         body.Name,
+		PasswordHash: hash,
+	})
+	if isDuplicate(err) {
+		writeError(w, http.StatusConflict, "user '%v' already exists", body.Name)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error creating user: %v", err)
+		return
+	}
+	writeUser(w, http.StatusCreated, user)
+}
+
+func (a *api) getUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	writeUser(w, http.StatusOK, user)
+}
+
+func writeUser(w http.ResponseWriter, status int, user database.User) {
+	writeRecord(w, status, []string{"id", "name", "created_at"}, user.ID, user.Name, user.CreatedAt)
+}
+
+func (a *api) listFeeds(w http.ResponseWriter, r *http.Request) {
+	feeds, err := a.s.Db.GetFeeds(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting feeds: %v", err)
+		return
+	}
+	records := Records{Fields: []string{"name", "url", "site_url", "user"}}
+	for _, feed := range feeds {
+		records.Add(feed.Name, feed.Url, feed.SiteUrl, feed.Username)
+	}
+	writeRecords(w, http.StatusOK, records)
+}
+
+// createFeed adds a feed and follows it, like addfeed
+func (a *api) createFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	var body struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+	if body.Name == "" || body.URL == "" {
+		writeError(w, http.StatusBadRequest, "name and url are required")
+		return
+	}
+	now := time.Now()
+	feed, err := a.s.Db.CreateFeed(r.Context(), database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      body.Name,
+		Url:       body.URL,
+		UserID:    user.ID,
+	})
+	if isDuplicate(err) {
+		writeError(w, http.StatusConflict, "feed %v already exists", body.URL)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error creating feed: %v", err)
+		return
+	}
+	_, err = a.s.Db.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error following feed: %v", err)
+		return
+	}
+	writeRecord(w, http.StatusCreated, []string{"id", "name", "url", "user", "created_at"},
+		feed.ID, feed.Name, feed.Url, user.Name, feed.CreatedAt)
+}
+
+func (a *api) listFollows(w http.ResponseWriter, r *http.Request, user database.User) {
+	follows, err := a.s.Db.GetFeedFollowsUser(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting following: %v", err)
+		return
+	}
+	records := Records{Fields: []string{"feed_name", "feed_url", "category", "followed_at"}}
+	for _, follow := range follows {
+		records.Add(follow.FeedName, follow.FeedUrl, follow.Category, follow.CreatedAt)
+	}
+	writeRecords(w, http.StatusOK, records)
+}
+
+func (a *api) follow(w http.ResponseWriter, r *http.Request, user database.User) {
+	var body struct {
+		URL      string `json:"url"`
+		Category string `json:"category"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+	if body.URL == "" {
+		writeError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+	feed, ok := a.feedByURL(w, r, body.URL)
+	if !ok {
+		return
+	}
+	// feed_follows has no unique constraint, so check first
+	_, err := a.s.Db.GetFeedFollow(r.Context(), database.GetFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feed.ID,
+	})
+	if err == nil {
+		writeError(w, http.StatusConflict, "already following %v", body.URL)
+		return
+	}
+	if err != sql.ErrNoRows {
+		writeError(w, http.StatusInternalServerError, "error finding follow: %v", err)
+		return
+	}
+	now := time.Now()
+	follow, err := a.s.Db.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+		Category:  nullString(body.Category),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error creating follow: %v", err)
+		return
+	}
+	writeRecord(w, http.StatusCreated, []string{"feed_name", "feed_url", "category", "followed_at"},
+		follow.FeedName, feed.Url, follow.Category, follow.CreatedAt)
+}
+
+// unfollow takes the feed as ?url=, since urls don't fit in a path segment
+func (a *api) unfollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		writeError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+	feed, ok := a.feedByURL(w, r, url)
+	if !ok {
+		return
+	}
+	_, err := a.s.Db.GetFeedFollow(r.Context(), database.GetFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feed.ID,
+	})
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "not following %v", url)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error finding follow: %v", err)
+		return
+	}
+	err = a.s.Db.DeleteFeedByUser(r.Context(), database.DeleteFeedByUserParams{
+		UserID: user.ID,
+		Url:    url,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error unfollowing feed: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) feedByURL(w http.ResponseWriter, r *http.Request, url string) (database.Feed, bool) {
+	feed, err := a.s.Db.GetFeedByURL(r.Context(), url)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "no feed with url %v", url)
+		return feed, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error finding feed: %v", err)
+		return feed, false
+	}
+	return feed, true
+}
+
+// browse takes the same filters as the browse command as query parameters
+func (a *api) browse(w http.ResponseWriter, r *http.Request, user database.User) {
+	query := r.URL.Query()
+	opts := BrowseOptions{
+		Limit:  20,
+		Page:   1,
+		Offset: -1,
+		Sort:   "published",
+		Feed:   query.Get("feed"),
+		Since:  query.Get("since"),
+		Until:  query.Get("until"),
+	}
+	if sort := query.Get("sort"); sort != "" {
+		opts.Sort = sort
+	}
+	var err error
+	for name, dest := range map[string]*int{"limit": &opts.Limit, "page": &opts.Page, "offset": &opts.Offset} {
+		if value := query.Get(name); value != "" {
+			if *dest, err = strconv.Atoi(value); err != nil {
+				writeError(w, http.StatusBadRequest, "%s must be a number", name)
+				return
+			}
+		}
+	}
+	for name, dest := range map[string]*bool{"unread": &opts.Unread, "asc": &opts.Asc} {
+		if value := query.Get(name); value != "" {
+			if *dest, err = strconv.ParseBool(value); err != nil {
+				writeError(w, http.StatusBadRequest, "%s must be true or false", name)
+				return
+			}
+		}
+	}
+
+	params, _, err := opts.params(user.ID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	posts, err := a.s.Db.GetPostsForUser(r.Context(), params)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting posts: %v", err)
+		return
+	}
+	records := Records{Fields: []string{"id", "title", "url", "description", "published_at", "fetched_at", "feed_id", "read"}}
+	for _, post := range posts {
+		records.Add(post.ID, html.UnescapeString(post.Title), post.Url, unescapeNull(post.Description), post.PublishedAt, post.CreatedAt, post.FeedID, post.IsRead)
+	}
+	writeRecords(w, http.StatusOK, records)
+}
+
+func (a *api) markRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	post, ok := a.postByID(w, r)
+	if !ok {
+		return
+	}
+	err := a.s.Db.MarkPostRead(r.Context(), database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error marking post read: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) markUnread(w http.ResponseWriter, r *http.Request, user database.User) {
+	post, ok := a.postByID(w, r)
+	if !ok {
+		return
+	}
+	err := a.s.Db.MarkPostUnread(r.Context(), database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error marking post unread: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) postByID(w http.ResponseWriter, r *http.Request) (database.Post, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid post id '%v'", r.PathValue("id"))
+		return database.Post{}, false
+	}
+	post, err := a.s.Db.GetPost(r.Context(), id)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "no post with id %v", id)
+		return post, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error finding post: %v", err)
+		return post, false
+	}
+	return post, true
+}
+
+// readBody decodes a JSON request body, answering 400 itself if it can't
+func readBody(w http.ResponseWriter, r *http.Request, dest any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := json.NewDecoder(r.Body).Decode(dest); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: %v", err)
+		return false
+	}
+	return true
+}
+
+func writeRecords(w http.ResponseWriter, status int, records Records) {
+	var buf bytes.Buffer
+	if err := renderRecords(&buf, OutputJSON, records); err != nil {
+		writeError(w, http.StatusInternalServerError, "error encoding response: %v", err)
+		return
+	}
+	writeJSON(w, status, buf.Bytes())
+}
+
+func writeRecord(w http.ResponseWriter, status int, fields []string, values ...any) {
+	var buf, out bytes.Buffer
+	err := writeObject(&buf, fields, values)
+	if err == nil {
+		err = json.Indent(&out, buf.Bytes(), "", "  ")
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error encoding response: %v", err)
+		return
+	}
+	out.WriteString("\n")
+	writeJSON(w, status, out.Bytes())
+}
+
+func writeError(w http.ResponseWriter, status int, format string, a ...any) {
+	msg := fmt.Sprintf(format, a...)
+	if status >= http.StatusInternalServerError {
+		log.Printf("API error: %s", msg)
+	}
+	body, _ := json.Marshal(map[string]string{"error": msg})
+	writeJSON(w, status, append(body, '\n'))
+}
+
+func writeJSON(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// isDuplicate reports whether err is a unique constraint violation
+func isDuplicate(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "duplicate key value violates unique constraint")
+}
@@ -0,0 +1,407 @@
+package config
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/frankielb/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+const testToken = tokenPrefix + "test"
+
+var (
+	userColumns     = []string{"id", "created_at", "updated_at", "name", "password_hash", "is_admin"}
+	apiTokenColumns = []string{"id", "user_id", "name", "token_hash", "scope", "created_at", "expires_at", "last_used_at"}
+	feedColumns     = []string{"id", "created_at", "updated_at", "name", "url", "user_id", "last_fetched_at", "etag", "last_modified", "next_fetch_at", "ttl_minutes", "skip_hours", "skip_days", "last_error", "last_error_at", "consecutive_failures", "last_status", "disabled", "site_url", "seq"}
+	followColumns   = []string{"id", "created_at", "updated_at", "user_id", "feed_id", "category"}
+	// CreateFeedFollow's row, the follow joined with the feed and user names
+	followRowColumns = append(followColumns[:len(followColumns):len(followColumns)], "feed_name", "user_name")
+	postColumns      = []string{"id", "created_at", "updated_at", "title", "url", "description", "published_at", "feed_id", "search_vector", "seq"}
+	// GetPostsForUser's row, the post with its read state and feed
+	postRowColumns = append(postColumns[:len(postColumns):len(postColumns)], "is_read", "feed_name", "feed_url", "feed_site_url")
+)
+
+// testAPI is the API over a mock database, for a user holding testToken
+type testAPI struct {
+	t       *testing.T
+	mock    sqlmock.Sqlmock
+	handler http.Handler
+	user    database.User
+	scope   string
+}
+
+func newTestAPI(t *testing.T, scope string) *testAPI {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet database expectations: %v", err)
+		}
+		db.Close()
+	})
+	s := &State{CurrentConfig: &Config{}, Db: database.New(db), Conn: db}
+	return &testAPI{
+		t:       t,
+		mock:    mock,
+		handler: NewAPI(s),
+		user:    database.User{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Name: "alice", IsAdmin: scope == ScopeAdmin},
+		scope:   scope,
+	}
+}
+
+// query matches a sqlc query by its name comment
+func query(name string) string {
+	return regexp.QuoteMeta("-- name: "+name+" ") + ".*"
+}
+
+func userValues(user database.User) []driver.Value {
+	return []driver.Value{user.ID.String(), user.CreatedAt, user.UpdatedAt, user.Name, nil, user.IsAdmin}
+}
+
+// expectAuth expects testToken to be looked up and touched
+func (ta *testAPI) expectAuth() {
+	tokenID := uuid.New()
+	values := []driver.Value{tokenID.String(), ta.user.ID.String(), "test", hashToken(testToken), ta.scope, time.Now(), nil, nil}
+	values = append(values, userValues(ta.user)...)
+	ta.mock.ExpectQuery(query("GetAPITokenUser")).
+		WithArgs(hashToken(testToken)).
+		WillReturnRows(sqlmock.NewRows(append(apiTokenColumns, userColumns...)).AddRow(values...))
+	ta.mock.ExpectExec(query("TouchAPIToken")).
+		WithArgs(tokenID.String()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func (ta *testAPI) expectFeed(url string) database.Feed {
+	feed := database.Feed{ID: uuid.New(), Name: "Example", Url: url, UserID: ta.user.ID}
+	ta.mock.ExpectQuery(query("GetFeedByURL")).
+		WithArgs(url).
+		WillReturnRows(sqlmock.NewRows(feedColumns).AddRow(
+			feed.ID.String(), time.Now(), time.Now(), feed.Name, feed.Url, feed.UserID.String(),
+			nil, nil, nil, nil, nil, 0, 0, nil, nil, 0, nil, false, nil, 1,
+		))
+	return feed
+}
+
+func (ta *testAPI) expectFollow(feed database.Feed, following bool) {
+	expect := ta.mock.ExpectQuery(query("GetFeedFollow")).WithArgs(ta.user.ID.String(), feed.ID.String())
+	if !following {
+		expect.WillReturnError(sql.ErrNoRows)
+		return
+	}
+	expect.WillReturnRows(sqlmock.NewRows(followColumns).AddRow(
+		uuid.New().String(), time.Now(), time.Now(), ta.user.ID.String(), feed.ID.String(), nil,
+	))
+}
+
+func (ta *testAPI) do(method, target, body string) *httptest.ResponseRecorder {
+	ta.t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+testToken)
+	w := httptest.NewRecorder()
+	ta.handler.ServeHTTP(w, r)
+	return w
+}
+
+func checkStatus(t *testing.T, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if w.Code != want {
+		t.Errorf("got status %d, want %d, body: %s", w.Code, want, w.Body.String())
+	}
+}
+
+// decodeBody checks the response is JSON and decodes it into v
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", ct)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("error decoding body: %v\n%s", err, w.Body.String())
+	}
+}
+
+// checkObject compares a decoded JSON object with want, field names included
+func checkObject(t *testing.T, got, want map[string]any) {
+	t.Helper()
+	for field, value := range want {
+		if v, ok := got[field]; !ok {
+			t.Errorf("missing field %q in %v", field, got)
+		} else if v != value {
+			t.Errorf("%s = %#v, want %#v", field, v, value)
+		}
+	}
+	for field := range got {
+		if _, ok := want[field]; !ok {
+			t.Errorf("unexpected field %q in %v", field, got)
+		}
+	}
+}
+
+func TestCreateUserBadRequest(t *testing.T) {
+	tests := map[string]string{
+		"invalid json":     `{"name":`,
+		"missing password": `{"name":"bob"}`,
+		"missing name":     `{"password":"secret"}`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			ta := newTestAPI(t, ScopeAdmin)
+			ta.expectAuth()
+			checkStatus(t, ta.do("POST", "/api/users", body), http.StatusBadRequest)
+		})
+	}
+}
+
+func TestCreateUserDuplicate(t *testing.T) {
+	ta := newTestAPI(t, ScopeAdmin)
+	ta.expectAuth()
+	ta.mock.ExpectQuery(query("CreateUser")).
+		WillReturnError(errors.New(`pq: duplicate key value violates unique constraint "users_name_key"`))
+	checkStatus(t, ta.do("POST", "/api/users", `{"name":"bob","password":"secret"}`), http.StatusConflict)
+}
+
+func TestCreateFeed(t *testing.T) {
+	ta := newTestAPI(t, ScopeWrite)
+	ta.expectAuth()
+	feedID := uuid.New()
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	ta.mock.ExpectQuery(query("CreateFeed")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "Example", "https://example.com/feed", ta.user.ID.String()).
+		WillReturnRows(sqlmock.NewRows(feedColumns).AddRow(
+			feedID.String(), created, created, "Example", "https://example.com/feed", ta.user.ID.String(),
+			nil, nil, nil, nil, nil, 0, 0, nil, nil, 0, nil, false, nil, 1,
+		))
+	ta.mock.ExpectQuery(query("CreateFeedFollow")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), ta.user.ID.String(), feedID.String(), nil).
+		WillReturnRows(sqlmock.NewRows(followRowColumns).AddRow(
+			uuid.New().String(), created, created, ta.user.ID.String(), feedID.String(), nil, "Example", "alice",
+		))
+
+	w := ta.do("POST", "/api/users/alice/feeds", `{"name":"Example","url":"https://example.com/feed"}`)
+	checkStatus(t, w, http.StatusCreated)
+	var got map[string]any
+	decodeBody(t, w, &got)
+	checkObject(t, got, map[string]any{
+		"id":         feedID.String(),
+		"name":       "Example",
+		"url":        "https://example.com/feed",
+		"user":       "alice",
+		"created_at": "2024-03-01T10:00:00Z",
+	})
+}
+
+func TestUnknownUser(t *testing.T) {
+	ta := newTestAPI(t, ScopeAdmin)
+	ta.expectAuth()
+	ta.mock.ExpectQuery(query("GetUser")).WithArgs("nobody").WillReturnError(sql.ErrNoRows)
+	checkStatus(t, ta.do("GET", "/api/users/nobody", ""), http.StatusNotFound)
+}
+
+func TestFollow(t *testing.T) {
+	ta := newTestAPI(t, ScopeWrite)
+	ta.expectAuth()
+	feed := ta.expectFeed("https://example.com/feed")
+	ta.expectFollow(feed, false)
+	followed := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	ta.mock.ExpectQuery(query("CreateFeedFollow")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), ta.user.ID.String(), feed.ID.String(), "tech").
+		WillReturnRows(sqlmock.NewRows(followRowColumns).AddRow(
+			uuid.New().String(), followed, followed, ta.user.ID.String(), feed.ID.String(), "tech", feed.Name, "alice",
+		))
+
+	w := ta.do("POST", "/api/users/alice/follows", `{"url":"https://example.com/feed","category":"tech"}`)
+	checkStatus(t, w, http.StatusCreated)
+	var got map[string]any
+	decodeBody(t, w, &got)
+	checkObject(t, got, map[string]any{
+		"feed_name":   "Example",
+		"feed_url":    "https://example.com/feed",
+		"category":    "tech",
+		"followed_at": "2024-03-01T10:00:00Z",
+	})
+}
+
+func TestFollowUnknownFeed(t *testing.T) {
+	ta := newTestAPI(t, ScopeWrite)
+	ta.expectAuth()
+	ta.mock.ExpectQuery(query("GetFeedByURL")).WithArgs("https://example.com/feed").WillReturnError(sql.ErrNoRows)
+	w := ta.do("POST", "/api/users/alice/follows", `{"url":"https://example.com/feed"}`)
+	checkStatus(t, w, http.StatusNotFound)
+}
+
+func TestFollowDuplicate(t *testing.T) {
+	ta := newTestAPI(t, ScopeWrite)
+	ta.expectAuth()
+	feed := ta.expectFeed("https://example.com/feed")
+	ta.expectFollow(feed, true)
+	w := ta.do("POST", "/api/users/alice/follows", `{"url":"https://example.com/feed"}`)
+	checkStatus(t, w, http.StatusConflict)
+}
+
+func TestUnfollow(t *testing.T) {
+	ta := newTestAPI(t, ScopeWrite)
+	ta.expectAuth()
+	feed := ta.expectFeed("https://example.com/feed")
+	ta.expectFollow(feed, true)
+	ta.mock.ExpectExec(query("DeleteFeedByUser")).
+		WithArgs(ta.user.ID.String(), feed.Url).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	w := ta.do("DELETE", "/api/users/alice/follows?url=https://example.com/feed", "")
+	checkStatus(t, w, http.StatusNoContent)
+}
+
+func TestUnfollowNotFollowing(t *testing.T) {
+	ta := newTestAPI(t, ScopeWrite)
+	ta.expectAuth()
+	feed := ta.expectFeed("https://example.com/feed")
+	ta.expectFollow(feed, false)
+	w := ta.do("DELETE", "/api/users/alice/follows?url=https://example.com/feed", "")
+	checkStatus(t, w, http.StatusNotFound)
+}
+
+func TestUnfollowMissingURL(t *testing.T) {
+	ta := newTestAPI(t, ScopeWrite)
+	ta.expectAuth()
+	checkStatus(t, ta.do("DELETE", "/api/users/alice/follows", ""), http.StatusBadRequest)
+}
+
+func TestBrowse(t *testing.T) {
+	ta := newTestAPI(t, ScopeRead)
+	ta.expectAuth()
+	feedID := uuid.New()
+	posts := []uuid.UUID{uuid.New(), uuid.New()}
+	published := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	fetched := time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)
+	ta.mock.ExpectQuery(query("GetPostsForUser")).
+		WithArgs(ta.user.ID.String(), true, nil, nil, false, nil, false, 5, 0).
+		WillReturnRows(sqlmock.NewRows(postRowColumns).
+			AddRow(posts[0].String(), fetched, fetched, "Fish &amp; chips", "https://example.com/1", "&lt;p&gt;Hi&lt;/p&gt;", published, feedID.String(), nil, 2, false, "Example", "https://example.com/feed", nil).
+			AddRow(posts[1].String(), fetched, fetched, "Undated", "https://example.com/2", nil, nil, feedID.String(), nil, 1, true, "Example", "https://example.com/feed", nil))
+
+	w := ta.do("GET", "/api/users/alice/posts?limit=5&unread=true", "")
+	checkStatus(t, w, http.StatusOK)
+	var got []map[string]any
+	decodeBody(t, w, &got)
+	if len(got) != 2 {
+		t.Fatalf("got %d posts, want 2: %v", len(got), got)
+	}
+	checkObject(t, got[0], map[string]any{
+		"id":           posts[0].String(),
+		"title":        "Fish & chips",
+		"url":          "https://example.com/1",
+		"description":  "<p>Hi</p>",
+		"published_at": "2024-03-01T10:00:00Z",
+		"fetched_at":   "2024-03-02T10:00:00Z",
+		"feed_id":      feedID.String(),
+		"read":         false,
+	})
+	checkObject(t, got[1], map[string]any{
+		"id":           posts[1].String(),
+		"title":        "Undated",
+		"url":          "https://example.com/2",
+		"description":  nil,
+		"published_at": nil,
+		"fetched_at":   "2024-03-02T10:00:00Z",
+		"feed_id":      feedID.String(),
+		"read":         true,
+	})
+}
+
+func TestBrowseBadParams(t *testing.T) {
+	tests := []string{
+		"limit=ten",
+		"limit=0",
+		"page=0",
+		"offset=x",
+		"unread=maybe",
+		"asc=2",
+		"sort=title",
+		"since=yesterday",
+	}
+	for _, params := range tests {
+		t.Run(params, func(t *testing.T) {
+			ta := newTestAPI(t, ScopeRead)
+			ta.expectAuth()
+			checkStatus(t, ta.do("GET", "/api/users/alice/posts?"+params, ""), http.StatusBadRequest)
+		})
+	}
+}
+
+func TestMarkRead(t *testing.T) {
+	ta := newTestAPI(t, ScopeWrite)
+	ta.expectAuth()
+	postID := uuid.New()
+	ta.mock.ExpectQuery(query("GetPost")).
+		WithArgs(postID.String()).
+		WillReturnRows(sqlmock.NewRows(postColumns).AddRow(
+			postID.String(), time.Now(), time.Now(), "First", "https://example.com/1", nil, nil, uuid.New().String(), nil, 1,
+		))
+	ta.mock.ExpectExec(query("MarkPostRead")).
+		WithArgs(ta.user.ID.String(), postID.String()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := ta.do("PUT", "/api/users/alice/posts/"+postID.String()+"/read", "")
+	checkStatus(t, w, http.StatusNoContent)
+	if w.Body.Len() != 0 {
+		t.Errorf("got body %q, want none", w.Body.String())
+	}
+}
+
+func TestMarkReadBadID(t *testing.T) {
+	ta := newTestAPI(t, ScopeWrite)
+	ta.expectAuth()
+	checkStatus(t, ta.do("PUT", "/api/users/alice/posts/not-a-uuid/read", ""), http.StatusBadRequest)
+}
+
+func TestMissingToken(t *testing.T) {
+	ta := newTestAPI(t, ScopeRead)
+	r := httptest.NewRequest("GET", "/api/feeds", nil)
+	w := httptest.NewRecorder()
+	ta.handler.ServeHTTP(w, r)
+	checkStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestOtherUserNeedsAdmin(t *testing.T) {
+	ta := newTestAPI(t, ScopeWrite)
+	ta.expectAuth()
+	checkStatus(t, ta.do("GET", "/api/users/bob/follows", ""), http.StatusForbidden)
+}
+
+func TestAdminTokenOfNonAdmin(t *testing.T) {
+	ta := newTestAPI(t, ScopeAdmin)
+	ta.user.IsAdmin = false
+	ta.mock.ExpectQuery(query("GetAPITokenUser")).
+		WithArgs(hashToken(testToken)).
+		WillReturnRows(sqlmock.NewRows(append(apiTokenColumns, userColumns...)).AddRow(append(
+			[]driver.Value{uuid.New().String(), ta.user.ID.String(), "test", hashToken(testToken), ScopeAdmin, time.Now(), nil, nil},
+			userValues(ta.user)...)...))
+	checkStatus(t, ta.do("GET", "/api/users", ""), http.StatusForbidden)
+}
+
+func TestFeedLinkHidesToken(t *testing.T) {
+	ta := newTestAPI(t, ScopeRead)
+	ta.expectAuth()
+	ta.mock.ExpectQuery(query("GetPostsForUser")).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	r := httptest.NewRequest("GET", "/api/users/alice/feed?format=rss&token="+testToken, nil)
+	w := httptest.NewRecorder()
+	ta.handler.ServeHTTP(w, r)
+	checkStatus(t, w, http.StatusOK)
+	if body := w.Body.String(); strings.Contains(body, testToken) {
+		t.Errorf("feed contains the token: %s", body)
+	} else if !strings.Contains(body, "/api/users/alice/feed?format=rss<") {
+		t.Errorf("feed link is missing: %s", body)
+	}
+}
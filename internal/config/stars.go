@@ -0,0 +1,81 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"html"
+
+	"github.com/frankielb/gator/internal/database"
+)
+
+func HandlerStar(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return cmd.UsageError("no post given")
+	}
+	post, err := resolvePost(s, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	err = s.Db.StarPost(context.Background(), database.StarPostParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error starring post: %v", err)
+	}
+	fmt.Printf("Starred: %s\n", post.Title)
+	return nil
+}
+
+func HandlerUnstar(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return cmd.UsageError("no post given")
+	}
+	post, err := resolvePost(s, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	n, err := s.Db.UnstarPost(context.Background(), database.UnstarPostParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error unstarring post: %v", err)
+	}
+	if n == 0 {
+		fmt.Printf("Post wasn't starred: %s\n", post.Title)
+		return nil
+	}
+	fmt.Printf("Unstarred: %s\n", post.Title)
+	return nil
+}
+
+func HandlerStarred(s *State, cmd Command, user database.User) error {
+	posts, err := s.Db.GetStarredPostsForUser(context.Background(), database.GetStarredPostsForUserParams{
+		UserID: user.ID,
+		Limit:  int32(cmd.Int("limit")),
+	})
+	if err != nil {
+		return fmt.Errorf("error getting starred posts: %v", err)
+	}
+	if s.Structured() {
+		records := Records{Fields: []string{"id", "title", "url", "published_at", "feed_id", "starred_at"}}
+		for _, post := range posts {
+			records.Add(post.ID, html.UnescapeString(post.Title), post.Url, post.PublishedAt, post.FeedID, post.StarredAt)
+		}
+		return s.Render(records)
+	}
+	if len(posts) == 0 {
+		fmt.Println("no starred posts")
+		return nil
+	}
+	fmt.Printf("Found %d starred posts:\n\n", len(posts))
+	for i, post := range posts {
+		fmt.Printf("%d. %s\n", i+1, post.Title)
+		fmt.Printf("   ID: %s\n", post.ID)
+		fmt.Printf("   URL: %s\n", post.Url)
+		fmt.Printf("   Starred: %s\n", post.StarredAt)
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/frankielb/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// token scopes, each one allows everything the ones before it do
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	// admin tokens can manage users and act on any user's data over the API,
+	// only admins can make them
+	ScopeAdmin = "admin"
+)
+
+var scopeRank = map[string]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+// envToken lets cron jobs run commands with a token instead of a login
+const envToken = "GATOR_TOKEN"
+
+// tokenPrefix marks gator tokens so they're easy to spot in scripts and logs
+const tokenPrefix = "gator_"
+
+var (
+	errTokenInvalid = errors.New("invalid or expired API token")
+	errTokenScope   = errors.New("API token doesn't have the scope needed")
+)
+
+func scopeAllows(have, need string) bool {
+	return scopeRank[have] >= scopeRank[need]
+}
+
+// tokenScope is what a token can do now, an admin token stops being one if
+// its user is no longer an admin
+func tokenScope(row database.GetAPITokenUserRow) string {
+	if row.ApiToken.Scope == ScopeAdmin && !row.User.IsAdmin {
+		return ScopeWrite
+	}
+	return row.ApiToken.Scope
+}
+
+// tokenUser checks an API token has at least scope and records that it was used
+func tokenUser(ctx context.Context, s *State, token, scope string) (database.GetAPITokenUserRow, error) {
+	row, err := s.Db.GetAPITokenUser(ctx, hashToken(token))
+	if err == sql.ErrNoRows {
+		return row, errTokenInvalid
+	}
+	if err != nil {
+		return row, fmt.Errorf("error checking API token: %v", err)
+	}
+	if !scopeAllows(tokenScope(row), scope) {
+		return row, errTokenScope
+	}
+	if err := s.Db.TouchAPIToken(ctx, row.ApiToken.ID); err != nil {
+		return row, fmt.Errorf("error updating API token: %v", err)
+	}
+	return row, nil
+}
+
+// MiddlewareToken passes the user of the token in GATOR_TOKEN if it's set and
+// has scope, otherwise the logged in user like MiddlewareLoggedIn
+func MiddlewareToken(scope string, handler func(s *State, cmd Command, user database.User) error) func(*State, Command) error {
+	return func(s *State, cmd Command) error {
+		token := os.Getenv(envToken)
+		if token == "" {
+			return MiddlewareLoggedIn(handler)(s, cmd)
+		}
+		row, err := tokenUser(context.Background(), s, token, scope)
+		if err == errTokenScope {
+			return fmt.Errorf("%s needs a token with %s scope", envToken, scope)
+		}
+		if err != nil {
+			return err
+		}
+		return handler(s, cmd, row.User)
+	}
+}
+
+// MiddlewareAdmin is MiddlewareToken for commands only an admin may run
+func MiddlewareAdmin(handler func(s *State, cmd Command, user database.User) error) func(*State, Command) error {
+	return MiddlewareToken(ScopeAdmin, func(s *State, cmd Command, user database.User) error {
+		if !user.IsAdmin {
+			return fmt.Errorf("only an admin can run %s", cmd.Name)
+		}
+		return handler(s, cmd, user)
+	})
+}
+
+// requireAdmin checks user may act on other users' data: they're an admin,
+// and if GATOR_TOKEN is set it's an admin token
+func requireAdmin(ctx context.Context, s *State, user database.User) error {
+	if !user.IsAdmin {
+		return fmt.Errorf("only an admin can do that for another user")
+	}
+	token := os.Getenv(envToken)
+	if token == "" {
+		return nil
+	}
+	_, err := tokenUser(ctx, s, token, ScopeAdmin)
+	if err == errTokenScope {
+		return fmt.Errorf("%s needs a token with %s scope", envToken, ScopeAdmin)
+	}
+	return err
+}
+
+// HandlerToken creates, lists and revokes the current user's API tokens
+func HandlerToken(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return cmd.UsageError("no token subcommand given")
+	}
+	switch cmd.Args[0] {
+	case "create":
+		return handlerTokenCreate(s, cmd, user)
+	case "list":
+		return handlerTokenList(s, cmd, user)
+	case "revoke":
+		return handlerTokenRevoke(s, cmd, user)
+	}
+	return cmd.UsageError("unknown token subcommand: %s", cmd.Args[0])
+}
+
+func handlerTokenCreate(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) < 2 {
+		return cmd.UsageError("no token name given")
+	}
+	name := cmd.Args[1]
+	scope := cmd.String("scope")
+	if _, ok := scopeRank[scope]; !ok {
+		return cmd.UsageError("scope must be read, write or admin")
+	}
+	if scope == ScopeAdmin && !user.IsAdmin {
+		return fmt.Errorf("only an admin can create admin tokens")
+	}
+	expires := cmd.Duration("expires")
+	if expires < 0 {
+		return cmd.UsageError("expires can't be negative")
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		return fmt.Errorf("error creating token: %v", err)
+	}
+	token := tokenPrefix + secret
+	now := time.Now()
+	params := database.CreateAPITokenParams{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: hashToken(token),
+		Scope:     scope,
+		CreatedAt: now,
+	}
+	if expires > 0 {
+		params.ExpiresAt = sql.NullTime{Time: now.Add(expires), Valid: true}
+	}
+	created, err := s.Db.CreateAPIToken(context.Background(), params)
+	if isDuplicate(err) {
+		return fmt.Errorf("you already have a token called '%v'", name)
+	}
+	if err != nil {
+		return fmt.Errorf("error creating token: %v", err)
+	}
+
+	fmt.Printf("Created %s token '%s'", created.Scope, created.Name)
+	if created.ExpiresAt.Valid {
+		fmt.Printf(", expires %v", created.ExpiresAt.Time.Format(time.RFC3339))
+	}
+	fmt.Println()
+	fmt.Println("It won't be shown again, so copy it now:")
+	fmt.Println(token)
+	return nil
+}
+
+func handlerTokenList(s *State, cmd Command, user database.User) error {
+	tokens, err := s.Db.GetAPITokensForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting tokens: %v", err)
+	}
+	if s.Structured() {
+		records := Records{Fields: []string{"id", "name", "scope", "created_at", "expires_at", "last_used_at"}}
+		for _, token := range tokens {
+			records.Add(token.ID, token.Name, token.Scope, token.CreatedAt, token.ExpiresAt, token.LastUsedAt)
+		}
+		return s.Render(records)
+	}
+	if len(tokens) == 0 {
+		fmt.Println("No API tokens, create one with 'gator token create <name>'")
+		return nil
+	}
+	for _, token := range tokens {
+		fmt.Printf("Token: %v (%s)\n -ID: %v\n", token.Name, token.Scope, token.ID)
+		fmt.Printf(" -Created: %v\n", token.CreatedAt.Format(time.RFC3339))
+		switch {
+		case !token.ExpiresAt.Valid:
+			fmt.Println(" -Expires: never")
+		case token.ExpiresAt.Time.Before(time.Now()):
+			fmt.Printf(" -Expired: %v\n", token.ExpiresAt.Time.Format(time.RFC3339))
+		default:
+			fmt.Printf(" -Expires: %v\n", token.ExpiresAt.Time.Format(time.RFC3339))
+		}
+		if token.LastUsedAt.Valid {
+			fmt.Printf(" -Last used: %v\n", token.LastUsedAt.Time.Format(time.RFC3339))
+		} else {
+			fmt.Println(" -Last used: never")
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func handlerTokenRevoke(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) < 2 {
+		return cmd.UsageError("no token name or id given")
+	}
+	ref := cmd.Args[1]
+	n, err := s.Db.RevokeAPIToken(context.Background(), database.RevokeAPITokenParams{
+		UserID: user.ID,
+		Ref:    ref,
+	})
+	if err != nil {
+		return fmt.Errorf("error revoking token: %v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no token called '%v'", ref)
+	}
+	fmt.Printf("Revoked token %v\n", ref)
+	return nil
+}
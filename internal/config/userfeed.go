@@ -0,0 +1,133 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/frankielb/gator/internal/database"
+	"github.com/frankielb/gator/internal/rss"
+)
+
+// handlerExportFeed writes a user's posts, from every feed they follow, as one feed
+func handlerExportFeed(s *State, cmd Command, user database.User) error {
+	format := cmd.String("format")
+	write, _, ok := feedWriter(format)
+	if !ok {
+		return cmd.UsageError("format must be atom or rss")
+	}
+	limit := cmd.Int("limit")
+	if limit < 1 {
+		return cmd.UsageError("limit must be at least 1")
+	}
+
+	ctx := context.Background()
+	user, err := exportUser(ctx, s, cmd, user)
+	if err != nil {
+		return err
+	}
+	feed, err := userFeed(ctx, s, user, limit)
+	if err != nil {
+		return err
+	}
+	feed.Link = cmd.String("link")
+	return write(os.Stdout, feed)
+}
+
+// userFeed builds the newest posts from a user's follows into a feed, each item
+// crediting the feed it came from
+func userFeed(ctx context.Context, s *State, user database.User, limit int) (*rss.Feed, error) {
+	posts, err := s.Db.GetPostsForUser(ctx, database.GetPostsForUserParams{
+		UserID:   user.ID,
+		PageSize: int32(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting posts: %v", err)
+	}
+
+	feed := &rss.Feed{
+		ID:          "urn:uuid:" + user.ID.String(),
+		Title:       fmt.Sprintf("%s on gator", user.Name),
+		Description: fmt.Sprintf("Posts from the feeds %s follows", user.Name),
+	}
+	for _, post := range posts {
+		// titles and descriptions are stored escaped, the xml encoder escapes them again
+		item := rss.Item{
+			ID:          "urn:uuid:" + post.ID.String(),
+			Title:       html.UnescapeString(post.Title),
+			Link:        post.Url,
+			Description: html.UnescapeString(post.Description.String),
+			Source: &rss.Source{
+				Title:   post.FeedName,
+				URL:     post.FeedUrl,
+				SiteURL: post.FeedSiteUrl.String,
+			},
+		}
+		// undated posts go by when they were fetched, like browse sorts them
+		item.PublishedAt = post.CreatedAt
+		if post.PublishedAt.Valid {
+			item.PublishedAt = post.PublishedAt.Time
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed, nil
+}
+
+// feedWriter picks the writer and content type for an output feed format
+func feedWriter(format string) (func(io.Writer, *rss.Feed) error, string, bool) {
+	switch format {
+	case "atom":
+		return rss.WriteAtom, "application/atom+xml", true
+	case "rss":
+		return rss.WriteRSS, "application/rss+xml", true
+	}
+	return nil, "", false
+}
+
+// feed serves GET /api/users/{name}/feed?format=atom|rss&limit=N
+func (a *api) feed(w http.ResponseWriter, r *http.Request, user database.User) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "atom"
+	}
+	write, contentType, ok := feedWriter(format)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "format must be atom or rss")
+		return
+	}
+	limit := 50
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+
+	feed, err := userFeed(r.Context(), a.s, user, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	// the link ends up in the feed, so it mustn't carry a ?token= secret
+	link := *r.URL
+	params := link.Query()
+	params.Del("token")
+	link.RawQuery = params.Encode()
+	feed.Link = scheme + "://" + r.Host + link.RequestURI()
+
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	if err := write(w, feed); err != nil {
+		log.Printf("API error: error writing feed: %v", err)
+	}
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,7 +23,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_status, disabled, site_url, seq
 `
 
 type CreateFeedParams struct {
@@ -52,24 +53,38 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.TtlMinutes,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastStatus,
+		&i.Disabled,
+		&i.SiteUrl,
+		&i.Seq,
 	)
 	return i, err
 }
 
 const createFeedFollow = `-- name: CreateFeedFollow :one
 WITH inserted_feed_follow AS (
-    INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+    INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id, category)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6
 )
-    RETURNING id, created_at, updated_at, user_id, feed_id
+    RETURNING id, created_at, updated_at, user_id, feed_id, category
 )
 SELECT
-inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id,
+inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id, inserted_feed_follow.category,
 feeds.name as feed_name,
 users.name as user_name
 FROM inserted_feed_follow
@@ -83,6 +98,7 @@ type CreateFeedFollowParams struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Category  sql.NullString
 }
 
 type CreateFeedFollowRow struct {
@@ -91,6 +107,7 @@ type CreateFeedFollowRow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Category  sql.NullString
 	FeedName  string
 	UserName  string
 }
@@ -102,6 +119,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		arg.UpdatedAt,
 		arg.UserID,
 		arg.FeedID,
+		arg.Category,
 	)
 	var i CreateFeedFollowRow
 	err := row.Scan(
@@ -110,6 +128,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.FeedID,
+		&i.Category,
 		&i.FeedName,
 		&i.UserName,
 	)
@@ -134,7 +153,7 @@ func (q *Queries) DeleteFeedByUser(ctx context.Context, arg DeleteFeedByUserPara
 }
 
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_status, disabled, site_url, seq
 FROM feeds
 WHERE url = $1
 `
@@ -150,13 +169,26 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.TtlMinutes,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastStatus,
+		&i.Disabled,
+		&i.SiteUrl,
+		&i.Seq,
 	)
 	return i, err
 }
 
 const getFeedFollowsUser = `-- name: GetFeedFollowsUser :many
 SELECT
-feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feeds.name as feed_name, users.name as user_name
+feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feed_follows.category, feeds.name as feed_name, feeds.url as feed_url, feeds.site_url as feed_site_url, users.name as user_name
 FROM feed_follows
 INNER JOIN feeds ON feeds.id = feed_follows.feed_id
 INNER JOIN users ON users.id = feed_follows.user_id
@@ -164,13 +196,16 @@ WHERE feed_follows.user_id = $1
 `
 
 type GetFeedFollowsUserRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	FeedID    uuid.UUID
-	FeedName  string
-	UserName  string
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	FeedID      uuid.UUID
+	Category    sql.NullString
+	FeedName    string
+	FeedUrl     string
+	FeedSiteUrl sql.NullString
+	UserName    string
 }
 
 func (q *Queries) GetFeedFollowsUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsUserRow, error) {
@@ -188,7 +223,10 @@ func (q *Queries) GetFeedFollowsUser(ctx context.Context, userID uuid.UUID) ([]G
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.FeedID,
+			&i.Category,
 			&i.FeedName,
+			&i.FeedUrl,
+			&i.FeedSiteUrl,
 			&i.UserName,
 		); err != nil {
 			return nil, err
@@ -205,7 +243,7 @@ func (q *Queries) GetFeedFollowsUser(ctx context.Context, userID uuid.UUID) ([]G
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT feeds.name, feeds.url, users.name as username
+SELECT feeds.name, feeds.url, feeds.site_url, users.name as username
 FROM feeds
 JOIN users on feeds.user_id = users.id
 `
@@ -213,6 +251,7 @@ JOIN users on feeds.user_id = users.id
 type GetFeedsRow struct {
 	Name     string
 	Url      string
+	SiteUrl  sql.NullString
 	Username string
 }
 
@@ -225,7 +264,12 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	var items []GetFeedsRow
 	for rows.Next() {
 		var i GetFeedsRow
-		if err := rows.Scan(&i.Name, &i.Url, &i.Username); err != nil {
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.SiteUrl,
+			&i.Username,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -240,7 +284,7 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 }
 
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_status, disabled, site_url, seq FROM feeds
 ORDER BY last_fetched_at NULLS FIRST
 LIMIT 1
 `
@@ -256,6 +300,19 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.TtlMinutes,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastStatus,
+		&i.Disabled,
+		&i.SiteUrl,
+		&i.Seq,
 	)
 	return i, err
 }
@@ -271,3 +328,305 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
 	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
 	return err
 }
+
+const updateFeedCache = `-- name: UpdateFeedCache :exec
+UPDATE feeds
+SET etag = $2,
+last_modified = $3,
+updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedCacheParams struct {
+	ID           uuid.UUID
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+func (q *Queries) UpdateFeedCache(ctx context.Context, arg UpdateFeedCacheParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedCache, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
+
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+UPDATE feeds
+SET last_fetched_at = NOW(),
+next_fetch_at = NOW() + INTERVAL '10 minutes',
+updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE NOT disabled
+    AND (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_status, disabled, site_url, seq
+`
+
+// next_fetch_at is pushed out as a lease so other agg processes skip these
+// feeds while they're being fetched, ScheduleFeedFetch replaces it after
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, limit int32) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.NextFetchAt,
+			&i.TtlMinutes,
+			&i.SkipHours,
+			&i.SkipDays,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.LastStatus,
+			&i.Disabled,
+			&i.SiteUrl,
+			&i.Seq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const scheduleFeedFetch = `-- name: ScheduleFeedFetch :exec
+UPDATE feeds
+SET next_fetch_at = NOW() + ($1::int * INTERVAL '1 second'),
+updated_at = NOW()
+WHERE id = $2
+`
+
+type ScheduleFeedFetchParams struct {
+	IntervalSeconds int32
+	ID              uuid.UUID
+}
+
+func (q *Queries) ScheduleFeedFetch(ctx context.Context, arg ScheduleFeedFetchParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleFeedFetch, arg.IntervalSeconds, arg.ID)
+	return err
+}
+
+const updateFeedHints = `-- name: UpdateFeedHints :exec
+UPDATE feeds
+SET ttl_minutes = $2,
+skip_hours = $3,
+skip_days = $4,
+updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedHintsParams struct {
+	ID         uuid.UUID
+	TtlMinutes sql.NullInt32
+	SkipHours  int32
+	SkipDays   int32
+}
+
+func (q *Queries) UpdateFeedHints(ctx context.Context, arg UpdateFeedHintsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedHints,
+		arg.ID,
+		arg.TtlMinutes,
+		arg.SkipHours,
+		arg.SkipDays,
+	)
+	return err
+}
+
+const recordFeedSuccess = `-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET consecutive_failures = 0,
+last_status = $2,
+updated_at = NOW()
+WHERE id = $1
+`
+
+type RecordFeedSuccessParams struct {
+	ID         uuid.UUID
+	LastStatus sql.NullInt32
+}
+
+func (q *Queries) RecordFeedSuccess(ctx context.Context, arg RecordFeedSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedSuccess, arg.ID, arg.LastStatus)
+	return err
+}
+
+const recordFeedFailure = `-- name: RecordFeedFailure :one
+UPDATE feeds
+SET consecutive_failures = consecutive_failures + 1,
+last_error = $1,
+last_error_at = NOW(),
+last_status = $2,
+disabled = disabled OR ($3::int > 0 AND consecutive_failures + 1 >= $3::int),
+updated_at = NOW()
+WHERE id = $4
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_status, disabled, site_url, seq
+`
+
+type RecordFeedFailureParams struct {
+	LastError   sql.NullString
+	LastStatus  sql.NullInt32
+	MaxFailures int32
+	ID          uuid.UUID
+}
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFailure,
+		arg.LastError,
+		arg.LastStatus,
+		arg.MaxFailures,
+		arg.ID,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.TtlMinutes,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastStatus,
+		&i.Disabled,
+		&i.SiteUrl,
+		&i.Seq,
+	)
+	return i, err
+}
+
+const getUnhealthyFeeds = `-- name: GetUnhealthyFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_status, disabled, site_url, seq FROM feeds
+WHERE consecutive_failures > 0 OR disabled
+ORDER BY disabled DESC, consecutive_failures DESC, name
+`
+
+func (q *Queries) GetUnhealthyFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getUnhealthyFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.NextFetchAt,
+			&i.TtlMinutes,
+			&i.SkipHours,
+			&i.SkipDays,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.LastStatus,
+			&i.Disabled,
+			&i.SiteUrl,
+			&i.Seq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const enableFeed = `-- name: EnableFeed :execrows
+UPDATE feeds
+SET disabled = FALSE,
+consecutive_failures = 0,
+next_fetch_at = NULL,
+updated_at = NOW()
+WHERE url = $1
+`
+
+func (q *Queries) EnableFeed(ctx context.Context, url string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableFeed, url)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFeedFollow = `-- name: GetFeedFollow :one
+SELECT id, created_at, updated_at, user_id, feed_id, category FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2
+`
+
+type GetFeedFollowParams struct {
+	UserID uuid.UUID
+	FeedID uuid.UUID
+}
+
+func (q *Queries) GetFeedFollow(ctx context.Context, arg GetFeedFollowParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollow, arg.UserID, arg.FeedID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.Category,
+	)
+	return i, err
+}
+
+const setFeedSiteURL = `-- name: SetFeedSiteURL :exec
+UPDATE feeds
+SET site_url = $2,
+updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedSiteURLParams struct {
+	ID      uuid.UUID
+	SiteUrl sql.NullString
+}
+
+func (q *Queries) SetFeedSiteURL(ctx context.Context, arg SetFeedSiteURLParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedSiteURL, arg.ID, arg.SiteUrl)
+	return err
+}
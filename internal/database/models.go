@@ -11,14 +11,38 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scope      string
+	CreatedAt  time.Time
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Name                string
+	Url                 string
+	UserID              uuid.UUID
+	LastFetchedAt       sql.NullTime
+	Etag                sql.NullString
+	LastModified        sql.NullString
+	NextFetchAt         sql.NullTime
+	TtlMinutes          sql.NullInt32
+	SkipHours           int32
+	SkipDays            int32
+	LastError           sql.NullString
+	LastErrorAt         sql.NullTime
+	ConsecutiveFailures int32
+	LastStatus          sql.NullInt32
+	Disabled            bool
+	SiteUrl             sql.NullString
+	Seq                 int64
 }
 
 type FeedFollow struct {
@@ -27,22 +51,52 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Category  sql.NullString
+}
+
+type FeverKey struct {
+	UserID    uuid.UUID
+	ApiKey    string
+	CreatedAt time.Time
 }
 
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Title        string
+	Url          string
+	Description  sql.NullString
+	PublishedAt  sql.NullTime
+	FeedID       uuid.UUID
+	SearchVector interface{}
+	Seq          int64
 }
 
-type User struct {
-	ID        uuid.UUID
+type PostRead struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+type PostStar struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	StarredAt time.Time
+}
+
+type Session struct {
+	TokenHash string
+	UserID    uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
+	ExpiresAt time.Time
+}
+
+type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash sql.NullString
+	IsAdmin      bool
 }
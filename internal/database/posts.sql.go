@@ -25,7 +25,7 @@ VALUES (
     $7,
     $8
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, search_vector, seq
 `
 
 type CreatePostParams struct {
@@ -60,33 +60,82 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.SearchVector,
+		&i.Seq,
 	)
 	return i, err
 }
 
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id FROM posts
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.search_vector, posts.seq, (post_reads.read_at IS NOT NULL)::bool AS is_read,
+feeds.name AS feed_name, feeds.url AS feed_url, feeds.site_url AS feed_site_url
+FROM posts
 JOIN feeds ON feeds.id = posts.feed_id
 JOIN feed_follows ON feeds.id = feed_follows.feed_id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
 WHERE feed_follows.user_id = $1
-ORDER BY posts.published_at DESC
-LIMIT $2
+AND (NOT $2::bool OR post_reads.read_at IS NULL)
+AND ($3::text IS NULL OR feeds.url = $3 OR feeds.name = $3)
+AND ($4::timestamp IS NULL OR (CASE WHEN $5::bool THEN posts.created_at ELSE COALESCE(posts.published_at, posts.created_at) END) >= $4)
+AND ($6::timestamp IS NULL OR (CASE WHEN $5::bool THEN posts.created_at ELSE COALESCE(posts.published_at, posts.created_at) END) < $6)
+-- undated posts sort by when they were fetched, id breaks any ties
+ORDER BY
+    CASE WHEN $7::bool THEN (CASE WHEN $5::bool THEN posts.created_at ELSE COALESCE(posts.published_at, posts.created_at) END) END ASC,
+    CASE WHEN NOT $7::bool THEN (CASE WHEN $5::bool THEN posts.created_at ELSE COALESCE(posts.published_at, posts.created_at) END) END DESC,
+    CASE WHEN $7::bool THEN posts.id END ASC,
+    CASE WHEN NOT $7::bool THEN posts.id END DESC
+LIMIT $8
+OFFSET $9
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID      uuid.UUID
+	UnreadOnly  bool
+	Feed        sql.NullString
+	Since       sql.NullTime
+	SortFetched bool
+	Until       sql.NullTime
+	SortAsc     bool
+	PageSize    int32
+	PageOffset  int32
+}
+
+type GetPostsForUserRow struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Title        string
+	Url          string
+	Description  sql.NullString
+	PublishedAt  sql.NullTime
+	FeedID       uuid.UUID
+	SearchVector interface{}
+	Seq          int64
+	IsRead       bool
+	FeedName     string
+	FeedUrl      string
+	FeedSiteUrl  sql.NullString
 }
 
-func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.Feed,
+		arg.Since,
+		arg.SortFetched,
+		arg.Until,
+		arg.SortAsc,
+		arg.PageSize,
+		arg.PageOffset,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostsForUserRow
 	for rows.Next() {
-		var i Post
+		var i GetPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
@@ -96,6 +145,181 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.SearchVector,
+			&i.Seq,
+			&i.IsRead,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.FeedSiteUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecentPostTimes = `-- name: GetRecentPostTimes :many
+SELECT COALESCE(published_at, created_at)::timestamp AS posted_at
+FROM posts
+WHERE feed_id = $1
+ORDER BY posted_at DESC
+LIMIT $2
+`
+
+type GetRecentPostTimesParams struct {
+	FeedID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetRecentPostTimes(ctx context.Context, arg GetRecentPostTimesParams) ([]time.Time, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentPostTimes, arg.FeedID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []time.Time
+	for rows.Next() {
+		var posted_at time.Time
+		if err := rows.Scan(&posted_at); err != nil {
+			return nil, err
+		}
+		items = append(items, posted_at)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countUnreadPostsForUser = `-- name: CountUnreadPostsForUser :one
+SELECT COUNT(*) FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+AND post_reads.read_at IS NULL
+`
+
+func (q *Queries) CountUnreadPostsForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnreadPostsForUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getPost = `-- name: GetPost :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, search_vector, seq FROM posts
+WHERE id = $1
+`
+
+func (q *Queries) GetPost(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.SearchVector,
+		&i.Seq,
+	)
+	return i, err
+}
+
+const getPostByURL = `-- name: GetPostByURL :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, search_vector, seq FROM posts
+WHERE url = $1
+`
+
+func (q *Queries) GetPostByURL(ctx context.Context, url string) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByURL, url)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.SearchVector,
+		&i.Seq,
+	)
+	return i, err
+}
+
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT posts.id, posts.title, posts.url, posts.published_at, feeds.name AS feed_name,
+ts_rank(posts.search_vector, tsq)::real AS rank,
+ts_headline('english', posts.title || ' ' || coalesce(posts.description, ''), tsq,
+    'StartSel=**, StopSel=**, MaxFragments=2, MaxWords=20, MinWords=5')::text AS snippet
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id,
+websearch_to_tsquery('english', $1) tsq
+WHERE feed_follows.user_id = $2
+AND posts.search_vector @@ tsq
+AND ($3::text IS NULL OR feeds.url = $3 OR feeds.name = $3)
+AND ($4::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= $4)
+ORDER BY rank DESC, posts.published_at DESC NULLS LAST
+LIMIT $5
+`
+
+type SearchPostsForUserParams struct {
+	Query    string
+	UserID   uuid.UUID
+	Feed     sql.NullString
+	Since    sql.NullTime
+	PageSize int32
+}
+
+type SearchPostsForUserRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	PublishedAt sql.NullTime
+	FeedName    string
+	Rank        float32
+	Snippet     string
+}
+
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser,
+		arg.Query,
+		arg.UserID,
+		arg.Feed,
+		arg.Since,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsForUserRow
+	for rows.Next() {
+		var i SearchPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
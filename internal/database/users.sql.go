@@ -7,27 +7,32 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, password_hash, is_admin)
 VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    -- the first user on a new install looks after it
+    NOT EXISTS (SELECT 1 FROM users)
 )
-RETURNING id, created_at, updated_at, name
+RETURNING id, created_at, updated_at, name, password_hash, is_admin
 `
 
 type CreateUserParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -36,6 +41,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.Name,
+		arg.PasswordHash,
 	)
 	var i User
 	err := row.Scan(
@@ -43,6 +49,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
+		&i.IsAdmin,
 	)
 	return i, err
 }
@@ -57,7 +65,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, name FROM users
+SELECT id, created_at, updated_at, name, password_hash, is_admin FROM users
 WHERE name = $1
 `
 
@@ -69,6 +77,8 @@ func (q *Queries) GetUser(ctx context.Context, name string) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
+		&i.IsAdmin,
 	)
 	return i, err
 }
@@ -99,3 +109,32 @@ func (q *Queries) GetUsers(ctx context.Context) ([]string, error) {
 	}
 	return items, nil
 }
+
+const hasAdmin = `-- name: HasAdmin :one
+SELECT EXISTS (
+    SELECT 1 FROM users WHERE is_admin
+)
+`
+
+func (q *Queries) HasAdmin(ctx context.Context) (bool, error) {
+	row := q.db.QueryRowContext(ctx, hasAdmin)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const setUserPassword = `-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetUserPasswordParams struct {
+	ID           uuid.UUID
+	PasswordHash sql.NullString
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPassword, arg.ID, arg.PasswordHash)
+	return err
+}
@@ -0,0 +1,306 @@
+// Package migrate applies goose-style sql migrations without needing goose installed
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionTable records which migrations have been applied
+const versionTable = "gator_schema_migrations"
+
+// Migration is one NNN_name.sql file split into its up and down sections
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and when it was applied, AppliedAt is invalid if it wasn't
+type Status struct {
+	Migration
+	AppliedAt sql.NullTime
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New loads the migrations in fsys, they're kept in version order
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load reads every .sql file at the top of fsys
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	seen := make(map[int64]string)
+	for _, file := range files {
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		m, err := parse(file, string(body))
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[m.Version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, file)
+		}
+		seen[m.Version] = file
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// parse splits a file on its "-- +goose Up" and "-- +goose Down" annotations
+func parse(file, body string) (Migration, error) {
+	name := strings.TrimSuffix(path.Base(file), ".sql")
+	prefix, _, _ := strings.Cut(name, "_")
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil || version < 1 {
+		return Migration{}, fmt.Errorf("migration %s does not start with a version number", file)
+	}
+	m := Migration{Version: version, Name: name}
+
+	var up, down strings.Builder
+	var section *strings.Builder
+	for _, line := range strings.SplitAfter(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if annotation, ok := strings.CutPrefix(trimmed, "-- +goose "); ok {
+			switch strings.ToLower(strings.TrimSpace(annotation)) {
+			case "up":
+				section = &up
+			case "down":
+				section = &down
+			}
+			// StatementBegin/End don't matter, each section runs as one exec
+			continue
+		}
+		if section != nil {
+			section.WriteString(line)
+		}
+	}
+	m.Up = strings.TrimSpace(up.String())
+	m.Down = strings.TrimSpace(down.String())
+	if m.Up == "" {
+		return Migration{}, fmt.Errorf("migration %s has no -- +goose Up section", file)
+	}
+	return m, nil
+}
+
+// Latest is the highest known version, 0 if there are no migrations
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version is the highest applied version, 0 for an empty database
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+	var version int64
+	err := m.db.QueryRowContext(ctx, "SELECT coalesce(max(version), 0) FROM "+versionTable).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("error reading schema version: %v", err)
+	}
+	return version, nil
+}
+
+// Status lists every known migration with when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			status.AppliedAt = sql.NullTime{Time: at, Valid: true}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration up to and including target, returning
+// the ones it ran. Each migration runs in its own transaction.
+func (m *Migrator) Up(ctx context.Context, target int64) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	for _, migration := range m.migrations {
+		if migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := m.inTx(ctx, migration.Up,
+			"INSERT INTO "+versionTable+" (version, applied_at) VALUES ($1, $2)",
+			migration.Version, time.Now())
+		if err != nil {
+			return ran, fmt.Errorf("error applying %s: %v", migration.Name, err)
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// Down rolls back applied migrations newer than target, newest first
+func (m *Migrator) Down(ctx context.Context, target int64) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version <= target {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return ran, fmt.Errorf("migration %s has no -- +goose Down section", migration.Name)
+		}
+		err := m.inTx(ctx, migration.Down,
+			"DELETE FROM "+versionTable+" WHERE version = $1",
+			migration.Version)
+		if err != nil {
+			return ran, fmt.Errorf("error rolling back %s: %v", migration.Name, err)
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// Previous is the version below the current one, what a single step down goes to
+func (m *Migrator) Previous(ctx context.Context) (int64, error) {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var previous int64
+	err = m.db.QueryRowContext(ctx,
+		"SELECT coalesce(max(version), 0) FROM "+versionTable+" WHERE version < $1",
+		current).Scan(&previous)
+	if err != nil {
+		return 0, fmt.Errorf("error reading schema version: %v", err)
+	}
+	return previous, nil
+}
+
+func (m *Migrator) inTx(ctx context.Context, script, record string, args ...any) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM "+versionTable)
+	if err != nil {
+		return nil, fmt.Errorf("error reading applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// ensureTable creates the version table. Databases set up with goose get their
+// applied versions copied over, so upgrading doesn't rerun old migrations.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	var exists bool
+	err := m.db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", versionTable).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking for %s: %v", versionTable, err)
+	}
+	if exists {
+		return nil
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE `+versionTable+` (
+    version BIGINT PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", versionTable, err)
+	}
+
+	var hasGoose bool
+	err = tx.QueryRowContext(ctx, "SELECT to_regclass('goose_db_version') IS NOT NULL").Scan(&hasGoose)
+	if err != nil {
+		return err
+	}
+	if hasGoose {
+		// goose adds a row for every up and down, only the newest says
+		// whether a version is applied now
+		_, err = tx.ExecContext(ctx, `INSERT INTO `+versionTable+` (version, applied_at)
+SELECT version_id, tstamp FROM (
+    SELECT DISTINCT ON (version_id) version_id, tstamp, is_applied
+    FROM goose_db_version
+    WHERE version_id > 0
+    ORDER BY version_id, id DESC
+) latest
+WHERE is_applied`)
+		if err != nil {
+			return fmt.Errorf("error copying goose versions: %v", err)
+		}
+	}
+	return tx.Commit()
+}
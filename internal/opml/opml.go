@@ -0,0 +1,126 @@
+package opml
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// OPML is an outline document, as used for sharing subscription lists
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title string `xml:"title,omitempty"`
+}
+
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+// Outline is either a feed (has an xmlUrl) or a folder of outlines
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Outlines []Outline `xml:"outline"`
+}
+
+// Entry is a feed outline flattened out of its folders
+type Entry struct {
+	Title   string
+	XMLURL  string
+	HTMLURL string
+	// folder path joined with "/", empty at the top level
+	Category string
+}
+
+func Parse(r io.Reader) (*OPML, error) {
+	var doc OPML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Entries walks the outline tree and returns every feed in document order
+func (o *OPML) Entries() []Entry {
+	var entries []Entry
+	walk(o.Body.Outlines, nil, &entries)
+	return entries
+}
+
+func walk(outlines []Outline, folders []string, entries *[]Entry) {
+	for _, outline := range outlines {
+		title := strings.TrimSpace(outline.Title)
+		if title == "" {
+			title = strings.TrimSpace(outline.Text)
+		}
+		if outline.XMLURL != "" {
+			*entries = append(*entries, Entry{
+				Title:    title,
+				XMLURL:   strings.TrimSpace(outline.XMLURL),
+				HTMLURL:  strings.TrimSpace(outline.HTMLURL),
+				Category: strings.Join(folders, "/"),
+			})
+			continue
+		}
+		if len(outline.Outlines) > 0 {
+			walk(outline.Outlines, append(folders, title), entries)
+		}
+	}
+}
+
+// Build nests entries into folder outlines by their category path
+func Build(title string, entries []Entry) *OPML {
+	doc := &OPML{
+		Version: "2.0",
+		Head:    Head{Title: title},
+	}
+	for _, entry := range entries {
+		outlines := &doc.Body.Outlines
+		if entry.Category != "" {
+			for _, folder := range strings.Split(entry.Category, "/") {
+				outlines = folderOutlines(outlines, folder)
+			}
+		}
+		*outlines = append(*outlines, Outline{
+			Text:    entry.Title,
+			Title:   entry.Title,
+			Type:    "rss",
+			XMLURL:  entry.XMLURL,
+			HTMLURL: entry.HTMLURL,
+		})
+	}
+	return doc
+}
+
+// folderOutlines finds or adds the named folder and returns its children
+func folderOutlines(outlines *[]Outline, name string) *[]Outline {
+	for i := range *outlines {
+		if (*outlines)[i].XMLURL == "" && (*outlines)[i].Text == name {
+			return &(*outlines)[i].Outlines
+		}
+	}
+	*outlines = append(*outlines, Outline{Text: name, Title: name})
+	return &(*outlines)[len(*outlines)-1].Outlines
+}
+
+func (o *OPML) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(o); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,92 @@
+package rss
+
+import "strings"
+
+type AtomFeed struct {
+	ID       string      `xml:"id"`
+	Title    AtomText    `xml:"title"`
+	Subtitle AtomText    `xml:"subtitle"`
+	Link     []AtomLink  `xml:"link"`
+	Entry    []AtomEntry `xml:"entry"`
+}
+
+type AtomEntry struct {
+	ID        string     `xml:"id"`
+	Title     AtomText   `xml:"title"`
+	Link      []AtomLink `xml:"link"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Summary   AtomText   `xml:"summary"`
+	Content   AtomText   `xml:"content"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// AtomText is a text construct, xhtml content keeps its markup
+type AtomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+func parseAtom(body []byte) (*Feed, error) {
+	var atomFeed AtomFeed
+	if err := unmarshalXML(body, &atomFeed); err != nil {
+		return nil, err
+	}
+	feed := &Feed{
+		ID:          strings.TrimSpace(atomFeed.ID),
+		Title:       atomFeed.Title.String(),
+		Link:        alternateLink(atomFeed.Link),
+		Description: atomFeed.Subtitle.String(),
+	}
+	for _, e := range atomFeed.Entry {
+		description := e.Summary.String()
+		if description == "" {
+			description = e.Content.String()
+		}
+		published := parseDate(e.Published)
+		if published.IsZero() {
+			published = parseDate(e.Updated)
+		}
+		feed.Items = append(feed.Items, Item{
+			ID:          strings.TrimSpace(e.ID),
+			Title:       e.Title.String(),
+			Link:        alternateLink(e.Link),
+			Description: description,
+			PublishedAt: published,
+		})
+	}
+	return feed, nil
+}
+
+// alternateLink picks the rel="alternate" link (the default rel), preferring html
+func alternateLink(links []AtomLink) string {
+	var found string
+	for _, l := range links {
+		if l.Rel != "" && l.Rel != "alternate" {
+			continue
+		}
+		if l.Type == "" || l.Type == "text/html" {
+			return l.Href
+		}
+		if found == "" {
+			found = l.Href
+		}
+	}
+	if found == "" && len(links) > 0 {
+		found = links[0].Href
+	}
+	return found
+}
@@ -0,0 +1,89 @@
+package rss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAtom(t *testing.T) {
+	runParseTests(t, []parseTest{
+		{
+			name: "alternate link",
+			body: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>urn:uuid:feed</id>
+  <title>Example</title>
+  <subtitle>All the news</subtitle>
+  <link rel="self" href="https://example.com/atom.xml"/>
+  <link href="https://example.com/"/>
+  <entry>
+    <id>urn:uuid:1</id>
+    <title>First</title>
+    <link rel="replies" href="https://example.com/1/comments"/>
+    <link rel="alternate" type="application/pdf" href="https://example.com/1.pdf"/>
+    <link rel="alternate" type="text/html" href="https://example.com/1"/>
+    <published>2024-03-01T10:00:00Z</published>
+    <updated>2024-03-02T10:00:00Z</updated>
+    <summary>Short</summary>
+  </entry>
+</feed>`,
+			want: Feed{
+				ID:          "urn:uuid:feed",
+				Title:       "Example",
+				Link:        "https://example.com/",
+				Description: "All the news",
+				Items: []Item{{
+					ID:          "urn:uuid:1",
+					Title:       "First",
+					Link:        "https://example.com/1",
+					Description: "Short",
+					PublishedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+				}},
+			},
+		},
+		{
+			name: "updated when not published",
+			body: `<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example</title>
+  <entry>
+    <id>https://example.com/2</id>
+    <title>No link</title>
+    <updated>2024-03-02T10:00:00+02:00</updated>
+    <content type="html">&lt;p&gt;Body&lt;/p&gt;</content>
+  </entry>
+</feed>`,
+			want: Feed{
+				Title: "Example",
+				Items: []Item{{
+					ID:    "https://example.com/2",
+					Title: "No link",
+					// the id is a permalink, so it stands in for the link
+					Link:        "https://example.com/2",
+					Description: "&lt;p&gt;Body&lt;/p&gt;",
+					PublishedAt: time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC),
+				}},
+			},
+		},
+		{
+			name: "xhtml content",
+			body: `<feed xmlns="http://www.w3.org/2005/Atom">
+  <title type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml">Example</div></title>
+  <entry>
+    <id>urn:uuid:3</id>
+    <title>Markup</title>
+    <link href="https://example.com/3"/>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Hi <b>there</b></p></div></content>
+  </entry>
+</feed>`,
+			want: Feed{
+				Title: `<div xmlns="http://www.w3.org/1999/xhtml">Example</div>`,
+				Items: []Item{{
+					ID:          "urn:uuid:3",
+					Title:       "Markup",
+					Link:        "https://example.com/3",
+					Description: "&lt;div xmlns=&#34;http://www.w3.org/1999/xhtml&#34;&gt;&lt;p&gt;Hi &lt;b&gt;there&lt;/b&gt;&lt;/p&gt;&lt;/div&gt;",
+				}},
+			},
+		},
+	})
+}
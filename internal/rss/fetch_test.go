@@ -0,0 +1,50 @@
+package rss
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchFeedTimeout(t *testing.T) {
+	defer func(old time.Duration) { fetchTimeout = old }(fetchTimeout)
+	fetchTimeout = 50 * time.Millisecond
+
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// send the headers then stall, like a host that never finishes the body
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	start := time.Now()
+	if _, err := FetchFeed(context.Background(), srv.URL, CacheHeaders{}); err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("fetch took %v, the timeout didn't apply", elapsed)
+	}
+}
+
+func TestFetchFeedTooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<rss><channel><title>"))
+		w.Write([]byte(strings.Repeat("x", maxFeedSize)))
+		w.Write([]byte("</title></channel></rss>"))
+	}))
+	defer srv.Close()
+
+	_, err := FetchFeed(context.Background(), srv.URL, CacheHeaders{})
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Fatalf("got error %v, want a size error", err)
+	}
+}
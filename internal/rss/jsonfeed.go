@@ -0,0 +1,91 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// JSONFeed covers the parts of JSON Feed 1.0 and 1.1 we use
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Description string         `json:"description"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+type JSONFeedItem struct {
+	ID            json.RawMessage `json:"id"`
+	URL           string          `json:"url"`
+	ExternalURL   string          `json:"external_url"`
+	Title         string          `json:"title"`
+	ContentHTML   string          `json:"content_html"`
+	ContentText   string          `json:"content_text"`
+	Summary       string          `json:"summary"`
+	DatePublished string          `json:"date_published"`
+	DateModified  string          `json:"date_modified"`
+}
+
+// isJSONFeed checks the content type first, then sniffs the body
+func isJSONFeed(body []byte, contentType string) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case "application/feed+json", "application/json":
+			return true
+		}
+	}
+	trimmed := bytes.TrimLeft(body, " \t\r\n\uFEFF")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+func parseJSONFeed(body []byte) (*Feed, error) {
+	var jsonFeed JSONFeed
+	if err := json.Unmarshal(body, &jsonFeed); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(jsonFeed.Version, "https://jsonfeed.org/version/") {
+		return nil, fmt.Errorf("not a JSON Feed: version %q", jsonFeed.Version)
+	}
+	feed := &Feed{
+		Title:       jsonFeed.Title,
+		Link:        jsonFeed.HomePageURL,
+		Description: jsonFeed.Description,
+	}
+	for _, i := range jsonFeed.Items {
+		link := i.URL
+		if link == "" {
+			link = i.ExternalURL
+		}
+		description := i.ContentHTML
+		if description == "" {
+			description = i.ContentText
+		}
+		if description == "" {
+			description = i.Summary
+		}
+		published := parseDate(i.DatePublished)
+		if published.IsZero() {
+			published = parseDate(i.DateModified)
+		}
+		feed.Items = append(feed.Items, Item{
+			ID:          jsonFeedID(i.ID),
+			Title:       i.Title,
+			Link:        link,
+			Description: description,
+			PublishedAt: published,
+		})
+	}
+	return feed, nil
+}
+
+// 1.0 feeds sometimes send the id as a number, 1.1 says it must be a string
+func jsonFeedID(raw json.RawMessage) string {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return id
+	}
+	return strings.TrimSpace(string(raw))
+}
@@ -0,0 +1,88 @@
+package rss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseJSONFeed(t *testing.T) {
+	runParseTests(t, []parseTest{
+		{
+			name:        "content type and numeric id",
+			contentType: "application/feed+json; charset=utf-8",
+			body: `{
+  "version": "https://jsonfeed.org/version/1",
+  "title": "Example",
+  "home_page_url": "https://example.com/",
+  "description": "All the news",
+  "items": [
+    {
+      "id": 42,
+      "url": "https://example.com/42",
+      "title": "Numbered",
+      "content_html": "<p>Hi</p>",
+      "date_published": "2024-03-01T10:00:00Z"
+    }
+  ]
+}`,
+			want: Feed{
+				Title:       "Example",
+				Link:        "https://example.com/",
+				Description: "All the news",
+				Items: []Item{{
+					ID:          "42",
+					Title:       "Numbered",
+					Link:        "https://example.com/42",
+					Description: "&lt;p&gt;Hi&lt;/p&gt;",
+					PublishedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+				}},
+			},
+		},
+		{
+			name: "sniffed with content_text fallback",
+			body: `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "Example",
+  "items": [
+    {
+      "id": "a",
+      "external_url": "https://other.example.com/a",
+      "title": "Text only",
+      "content_text": "plain & simple",
+      "date_modified": "2024-03-02T10:00:00Z"
+    },
+    {
+      "id": "b",
+      "url": "https://example.com/b",
+      "title": "Summary only",
+      "summary": "short"
+    }
+  ]
+}`,
+			want: Feed{
+				Title: "Example",
+				Items: []Item{
+					{
+						ID:          "a",
+						Title:       "Text only",
+						Link:        "https://other.example.com/a",
+						Description: "plain &amp; simple",
+						PublishedAt: time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC),
+					},
+					{
+						ID:          "b",
+						Title:       "Summary only",
+						Link:        "https://example.com/b",
+						Description: "short",
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestParseJSONFeedVersion(t *testing.T) {
+	if _, err := ParseFeed([]byte(`{"title": "not a feed"}`), "application/json"); err == nil {
+		t.Error("expected an error for JSON without a JSON Feed version")
+	}
+}
@@ -0,0 +1,41 @@
+package rss
+
+// RDFFeed is RSS 1.0, where items sit next to the channel rather than in it
+type RDFFeed struct {
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+	} `xml:"channel"`
+	Item []RDFItem `xml:"item"`
+}
+
+type RDFItem struct {
+	About       string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+func parseRDF(body []byte) (*Feed, error) {
+	var rdfFeed RDFFeed
+	if err := unmarshalXML(body, &rdfFeed); err != nil {
+		return nil, err
+	}
+	feed := &Feed{
+		Title:       rdfFeed.Channel.Title,
+		Link:        rdfFeed.Channel.Link,
+		Description: rdfFeed.Channel.Description,
+	}
+	for _, i := range rdfFeed.Item {
+		feed.Items = append(feed.Items, Item{
+			ID:          i.About,
+			Title:       i.Title,
+			Link:        i.Link,
+			Description: i.Description,
+			PublishedAt: parseDate(i.Date),
+		})
+	}
+	return feed, nil
+}
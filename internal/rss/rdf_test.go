@@ -0,0 +1,59 @@
+package rss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRDF(t *testing.T) {
+	runParseTests(t, []parseTest{
+		{
+			name: "sibling items with dc:date",
+			body: `<?xml version="1.0" encoding="utf-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+         xmlns="http://purl.org/rss/1.0/"
+         xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="https://example.com/">
+    <title>Example</title>
+    <link>https://example.com/</link>
+    <description>All the news</description>
+    <items>
+      <rdf:Seq>
+        <rdf:li rdf:resource="https://example.com/1"/>
+        <rdf:li rdf:resource="https://example.com/2"/>
+      </rdf:Seq>
+    </items>
+  </channel>
+  <item rdf:about="https://example.com/1">
+    <title>First</title>
+    <link>https://example.com/1</link>
+    <description>One</description>
+    <dc:date>2024-03-01T10:00:00+01:00</dc:date>
+  </item>
+  <item rdf:about="https://example.com/2">
+    <title>Second</title>
+    <link>https://example.com/2</link>
+  </item>
+</rdf:RDF>`,
+			want: Feed{
+				Title:       "Example",
+				Link:        "https://example.com/",
+				Description: "All the news",
+				Items: []Item{
+					{
+						ID:          "https://example.com/1",
+						Title:       "First",
+						Link:        "https://example.com/1",
+						Description: "One",
+						PublishedAt: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+					},
+					{
+						ID:    "https://example.com/2",
+						Title: "Second",
+						Link:  "https://example.com/2",
+					},
+				},
+			},
+		},
+	})
+}
@@ -1,19 +1,79 @@
 package rss
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
 )
 
+// Feed is the normalized form every supported format is parsed into
+type Feed struct {
+	// only Atom feeds have one
+	ID          string
+	Title       string
+	Link        string
+	Description string
+	Items       []Item
+	// validators from the response, send them back on the next fetch
+	Cache CacheHeaders
+	// publisher polling hints, only RSS 2.0 has these
+	TTL       time.Duration
+	SkipHours []int
+	SkipDays  []time.Weekday
+}
+
+// CacheHeaders are the validators used for conditional GETs
+type CacheHeaders struct {
+	ETag         string
+	LastModified string
+}
+
+// ErrNotModified is returned when the server answers 304 to a conditional GET
+var ErrNotModified = errors.New("feed not modified")
+
+// HTTPError is returned for any other non-200 response
+type HTTPError struct {
+	StatusCode int
+	// only set for 429 and 503, zero if the server didn't say
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("HTTP request failed: %v (retry after %v)", e.StatusCode, e.RetryAfter)
+	}
+	return fmt.Sprintf("HTTP request failed: %v", e.StatusCode)
+}
+
+type Item struct {
+	ID          string
+	Title       string
+	Link        string
+	Description string
+	// zero if the feed gave no usable date
+	PublishedAt time.Time
+	// where the item came from, only set when republishing it
+	Source *Source
+}
+
 type RSSFeed struct {
 	Channel struct {
 		Title       string    `xml:"title"`
 		Link        string    `xml:"link"`
 		Description string    `xml:"description"`
+		TTL         string    `xml:"ttl"`
+		SkipHours   []int     `xml:"skipHours>hour"`
+		SkipDays    []string  `xml:"skipDays>day"`
 		Item        []RSSItem `xml:"item"`
 	} `xml:"channel"`
 }
@@ -23,41 +83,221 @@ type RSSItem struct {
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	GUID        string `xml:"guid"`
 }
 
-func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+// a stalled feed host shouldn't hold up a worker for longer than this
+var fetchTimeout = 30 * time.Second
+
+// maxFeedSize caps how much of a response is read, feeds are rarely over 1MB
+const maxFeedSize = 10 << 20
+
+func FetchFeed(ctx context.Context, feedURL string, cache CacheHeaders) (*Feed, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("User-Agent", "gator")
+	if cache.ETag != "" {
+		req.Header.Set("If-None-Match", cache.ETag)
+	}
+	if cache.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cache.LastModified)
+	}
 
-	client := &http.Client{}
+	client := &http.Client{Timeout: fetchTimeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP request failed: %v", resp.StatusCode)
+		httpErr := &HTTPError{StatusCode: resp.StatusCode}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			httpErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return nil, httpErr
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
 	if err != nil {
 		return nil, err
 	}
-	var rssFeed RSSFeed
-	err = xml.Unmarshal(body, &rssFeed)
+	if len(body) > maxFeedSize {
+		return nil, fmt.Errorf("feed is larger than %d MB", maxFeedSize>>20)
+	}
+	feed, err := ParseFeed(body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	feed.Cache = CacheHeaders{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return feed, nil
+}
+
+// ParseFeed works out the format from the content type or the body and parses it
+func ParseFeed(body []byte, contentType string) (*Feed, error) {
+	if isJSONFeed(body, contentType) {
+		feed, err := parseJSONFeed(body)
+		if err != nil {
+			return nil, err
+		}
+		cleanFeed(feed)
+		return feed, nil
+	}
+
+	root, err := rootElement(body)
 	if err != nil {
 		return nil, err
 	}
-	// some characters go weird in xml
-	rssFeed.Channel.Title = html.UnescapeString(rssFeed.Channel.Title)
-	rssFeed.Channel.Description = html.EscapeString(rssFeed.Channel.Description)
-	for i := range rssFeed.Channel.Item {
-		rssFeed.Channel.Item[i].Title = html.EscapeString(rssFeed.Channel.Item[i].Title)
-		rssFeed.Channel.Item[i].Description = html.EscapeString(rssFeed.Channel.Item[i].Description)
+
+	var feed *Feed
+	switch root {
+	case "rss":
+		feed, err = parseRSS(body)
+	case "feed":
+		feed, err = parseAtom(body)
+	case "RDF":
+		feed, err = parseRDF(body)
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%s>", root)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cleanFeed(feed)
+	return feed, nil
+}
+
+// rootElement returns the local name of the first element in the document
+func rootElement(body []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	// only the name is wanted here, so the charset doesn't matter
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("could not find root element: %v", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// unmarshalXML is xml.Unmarshal that also reads documents declaring another
+// encoding, older feeds are often ISO-8859-1 or windows-1252
+func unmarshalXML(body []byte, v any) error {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder.Decode(v)
+}
+
+func parseRSS(body []byte) (*Feed, error) {
+	var rssFeed RSSFeed
+	if err := unmarshalXML(body, &rssFeed); err != nil {
+		return nil, err
+	}
+	feed := &Feed{
+		Title:       rssFeed.Channel.Title,
+		Link:        rssFeed.Channel.Link,
+		Description: rssFeed.Channel.Description,
+		SkipHours:   rssFeed.Channel.SkipHours,
+	}
+	if ttl, err := strconv.Atoi(strings.TrimSpace(rssFeed.Channel.TTL)); err == nil && ttl > 0 {
+		feed.TTL = time.Duration(ttl) * time.Minute
+	}
+	for _, day := range rssFeed.Channel.SkipDays {
+		if weekday, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]; ok {
+			feed.SkipDays = append(feed.SkipDays, weekday)
+		}
+	}
+	for _, i := range rssFeed.Channel.Item {
+		feed.Items = append(feed.Items, Item{
+			ID:          i.GUID,
+			Title:       i.Title,
+			Link:        i.Link,
+			Description: i.Description,
+			PublishedAt: parseDate(i.PubDate),
+		})
+	}
+	return feed, nil
+}
+
+// some characters go weird in xml
+func cleanFeed(feed *Feed) {
+	feed.Title = html.UnescapeString(feed.Title)
+	feed.Description = html.EscapeString(feed.Description)
+	for i := range feed.Items {
+		feed.Items[i].Title = html.EscapeString(strings.TrimSpace(feed.Items[i].Title))
+		feed.Items[i].Description = html.EscapeString(feed.Items[i].Description)
+		feed.Items[i].Link = strings.TrimSpace(feed.Items[i].Link)
+		// posts are keyed by url, so fall back to a permalink id
+		if feed.Items[i].Link == "" && strings.HasPrefix(feed.Items[i].ID, "http") {
+			feed.Items[i].Link = feed.Items[i].ID
+		}
+	}
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// parseRetryAfter handles both the delay-seconds and HTTP-date forms
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z",
+	"2006-01-02T15:04Z07:00",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02",
+}
+
+// parseDate tries the usual feed date formats, zero time if none match
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
 	}
-	return &rssFeed, nil
+	fmt.Printf("Could not parse date '%s'\n", s)
+	return time.Time{}
 }
@@ -0,0 +1,77 @@
+package rss
+
+import (
+	"testing"
+	"time"
+)
+
+// parseTest is one ParseFeed case, the feed's items are compared field by field
+type parseTest struct {
+	name        string
+	contentType string
+	body        string
+	want        Feed
+}
+
+func runParseTests(t *testing.T, tests []parseTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFeed([]byte(tt.body), tt.contentType)
+			if err != nil {
+				t.Fatalf("ParseFeed: %v", err)
+			}
+			checkFeed(t, got, &tt.want)
+		})
+	}
+}
+
+func checkFeed(t *testing.T, got, want *Feed) {
+	t.Helper()
+	if got.ID != want.ID || got.Title != want.Title || got.Link != want.Link || got.Description != want.Description {
+		t.Errorf("feed = {%q %q %q %q}, want {%q %q %q %q}",
+			got.ID, got.Title, got.Link, got.Description,
+			want.ID, want.Title, want.Link, want.Description)
+	}
+	if len(got.Items) != len(want.Items) {
+		t.Fatalf("got %d items, want %d", len(got.Items), len(want.Items))
+	}
+	for i, item := range got.Items {
+		w := want.Items[i]
+		if item.ID != w.ID || item.Title != w.Title || item.Link != w.Link || item.Description != w.Description {
+			t.Errorf("item %d = {%q %q %q %q}, want {%q %q %q %q}", i,
+				item.ID, item.Title, item.Link, item.Description,
+				w.ID, w.Title, w.Link, w.Description)
+		}
+		if !item.PublishedAt.Equal(w.PublishedAt) {
+			t.Errorf("item %d published %v, want %v", i, item.PublishedAt, w.PublishedAt)
+		}
+	}
+}
+
+func TestParseRSSCharset(t *testing.T) {
+	// "Café" with é as the single ISO-8859-1 byte 0xE9
+	body := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		"<rss version=\"2.0\"><channel><title>Caf\xe9</title><link>https://example.com/</link>" +
+		"<item><title>Cr\xe8me</title><link>https://example.com/1</link>" +
+		"<pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate></item></channel></rss>"
+	runParseTests(t, []parseTest{{
+		name: "iso-8859-1",
+		body: body,
+		want: Feed{
+			Title: "Café",
+			Link:  "https://example.com/",
+			Items: []Item{{
+				Title:       "Crème",
+				Link:        "https://example.com/1",
+				PublishedAt: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+			}},
+		},
+	}})
+}
+
+func TestParseFeedUnsupported(t *testing.T) {
+	if _, err := ParseFeed([]byte(`<html><body>not a feed</body></html>`), "text/html"); err == nil {
+		t.Error("expected an error for an html page")
+	}
+}
@@ -0,0 +1,195 @@
+package rss
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Source credits the feed an item was taken from when it's republished
+type Source struct {
+	Title string
+	URL   string
+	// the feed's website, empty if not known
+	SiteURL string
+}
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+type atomOutFeed struct {
+	XMLName   xml.Name       `xml:"feed"`
+	Namespace string         `xml:"xmlns,attr"`
+	ID        string         `xml:"id"`
+	Title     string         `xml:"title"`
+	Subtitle  string         `xml:"subtitle,omitempty"`
+	Updated   string         `xml:"updated"`
+	Author    atomOutPerson  `xml:"author"`
+	Links     []atomOutLink  `xml:"link"`
+	Generator string         `xml:"generator"`
+	Entries   []atomOutEntry `xml:"entry"`
+}
+
+type atomOutEntry struct {
+	ID        string         `xml:"id"`
+	Title     string         `xml:"title"`
+	Links     []atomOutLink  `xml:"link"`
+	Updated   string         `xml:"updated"`
+	Published string         `xml:"published,omitempty"`
+	Summary   *atomOutText   `xml:"summary"`
+	Source    *atomOutSource `xml:"source"`
+}
+
+type atomOutPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomOutLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomOutText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type atomOutSource struct {
+	ID    string        `xml:"id"`
+	Title string        `xml:"title"`
+	Links []atomOutLink `xml:"link"`
+}
+
+type rssOutFeed struct {
+	XMLName xml.Name      `xml:"rss"`
+	Version string        `xml:"version,attr"`
+	Channel rssOutChannel `xml:"channel"`
+}
+
+type rssOutChannel struct {
+	Title         string       `xml:"title"`
+	Link          string       `xml:"link"`
+	Description   string       `xml:"description"`
+	Generator     string       `xml:"generator"`
+	LastBuildDate string       `xml:"lastBuildDate"`
+	Items         []rssOutItem `xml:"item"`
+}
+
+type rssOutItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description,omitempty"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	GUID        rssOutGUID    `xml:"guid"`
+	Source      *rssOutSource `xml:"source"`
+}
+
+type rssOutGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssOutSource struct {
+	URL   string `xml:"url,attr"`
+	Title string `xml:",chardata"`
+}
+
+// WriteAtom writes feed as an Atom 1.0 document. Feed.ID is the feed's id,
+// Feed.Link is where it's served from, and items with no date use now.
+func WriteAtom(w io.Writer, feed *Feed) error {
+	now := time.Now()
+	out := atomOutFeed{
+		Namespace: atomNamespace,
+		ID:        feed.ID,
+		Title:     feed.Title,
+		Subtitle:  feed.Description,
+		Author:    atomOutPerson{Name: "gator"},
+		Generator: "gator",
+	}
+	if feed.Link != "" {
+		out.Links = append(out.Links, atomOutLink{Href: feed.Link, Rel: "self", Type: "application/atom+xml"})
+	}
+
+	var updated time.Time
+	for _, item := range feed.Items {
+		date := item.PublishedAt
+		if date.IsZero() {
+			date = now
+		}
+		if date.After(updated) {
+			updated = date
+		}
+		entry := atomOutEntry{
+			ID:      item.ID,
+			Title:   item.Title,
+			Links:   []atomOutLink{{Href: item.Link, Rel: "alternate"}},
+			Updated: date.UTC().Format(time.RFC3339),
+		}
+		if !item.PublishedAt.IsZero() {
+			entry.Published = entry.Updated
+		}
+		if item.Description != "" {
+			entry.Summary = &atomOutText{Type: "html", Text: item.Description}
+		}
+		if item.Source != nil {
+			source := &atomOutSource{
+				ID:    item.Source.URL,
+				Title: item.Source.Title,
+				Links: []atomOutLink{{Href: item.Source.URL, Rel: "self"}},
+			}
+			if item.Source.SiteURL != "" {
+				source.Links = append(source.Links, atomOutLink{Href: item.Source.SiteURL, Rel: "alternate"})
+			}
+			entry.Source = source
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+	if updated.IsZero() {
+		updated = now
+	}
+	out.Updated = updated.UTC().Format(time.RFC3339)
+	return writeXML(w, out)
+}
+
+// WriteRSS writes feed as an RSS 2.0 document, Feed.Link is the channel link
+func WriteRSS(w io.Writer, feed *Feed) error {
+	out := rssOutFeed{
+		Version: "2.0",
+		Channel: rssOutChannel{
+			Title:         feed.Title,
+			Link:          feed.Link,
+			Description:   feed.Description,
+			Generator:     "gator",
+			LastBuildDate: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, item := range feed.Items {
+		rssItem := rssOutItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			GUID:        rssOutGUID{Value: item.ID},
+		}
+		if !item.PublishedAt.IsZero() {
+			rssItem.PubDate = item.PublishedAt.UTC().Format(time.RFC1123Z)
+		}
+		if item.Source != nil {
+			rssItem.Source = &rssOutSource{URL: item.Source.URL, Title: item.Source.Title}
+		}
+		out.Channel.Items = append(out.Channel.Items, rssItem)
+	}
+	return writeXML(w, out)
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
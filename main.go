@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/frankielb/gator/internal/config"
 	"github.com/frankielb/gator/internal/database"
@@ -12,48 +13,261 @@ import (
 )
 
 func main() {
-	// Load config
-	cfg, err := config.Read()
+	// --db can go anywhere on the line, it's needed before the command runs
+	dbFlag, args, err := config.ExtractGlobal(os.Args[1:], "db")
 	if err != nil {
-		log.Fatalf("Failed to read config: %v", err)
-	}
-	// Connect to DB
-
-	db, err := sql.Open("postgres", DbURL)
-	if err != nil {
-		log.Fatalf("Failed to open databse: %v", err)
-	}
-	defer db.Close()
-	// Generate sqlc queries
-	dbQueries := database.New(db)
-
-	state := config.State{
-		CurrentConfig: &cfg,
-		Db:            dbQueries,
+		log.Fatal(err)
 	}
+	var state config.State
+	defer func() {
+		if state.Conn != nil {
+			state.Conn.Close()
+		}
+	}()
 
 	commands := config.Commands{
 		Handlers: make(map[string]func(*config.State, config.Command) error),
+		Connect: func(s *config.State) error {
+			// Load config: file, then environment, then --db
+			cfg, err := config.Load(dbFlag)
+			if err != nil {
+				return fmt.Errorf("failed to read config: %v", err)
+			}
+			// Connect to DB
+			db, err := sql.Open("postgres", cfg.DbURL)
+			if err != nil {
+				return fmt.Errorf("failed to open database: %v", err)
+			}
+			// Generate sqlc queries
+			s.CurrentConfig = &cfg
+			s.Db = database.New(db)
+			s.Conn = db
+			return nil
+		},
 	}
-	commands.Register("login", config.HandlerLogin)
-	commands.Register("register", config.HandlerRegister)
-	commands.Register("reset", config.HandlerReset)
-	commands.Register("users", config.HandlerUsers)
-	commands.Register("agg", config.HandlerAgg)
-	commands.Register("addfeed", config.MiddlewareLoggedIn(config.HandlerAddFeed))
-	commands.Register("feeds", config.HandlerFeeds)
-	commands.Register("follow", config.MiddlewareLoggedIn(config.HandlerFollow))
-	commands.Register("following", config.MiddlewareLoggedIn(config.HandlerFollowing))
-	commands.Register("unfollow", config.MiddlewareLoggedIn(config.HandlerUnfollow))
-	commands.Register("browse", config.MiddlewareLoggedIn(config.HandlerBrowse))
+	commands.Register("help", commands.HandlerHelp, config.CommandInfo{
+		Description: "List commands, or show how to use one",
+		Usage:       "help [command]",
+		MaxArgs:     1,
+		NoDB:        true,
+	})
+	commands.Register("login", config.HandlerLogin, config.CommandInfo{
+		Description: "Log in as an existing user, asks for their password",
+		Usage:       "login <name>",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Complete:    config.CompleteUsers,
+	})
+	commands.Register("register", config.HandlerRegister, config.CommandInfo{
+		Description: "Create a user with a password and log in as them, the first user is the admin",
+		Usage:       "register <name>",
+		MinArgs:     1,
+		MaxArgs:     1,
+	})
+	commands.Register("passwd", config.HandlerPasswd, config.CommandInfo{
+		Description: "Change your password, or as an admin set another user's",
+		Usage:       "passwd [flags]",
+		Flags: []config.Flag{
+			{Name: "user", Default: "", Usage: "user whose password to set (admin only)"},
+		},
+	})
+	commands.Register("reset", config.MiddlewareAdmin(config.HandlerReset), config.CommandInfo{
+		Description: "Delete every user, along with their feeds and posts (admin only)",
+	})
+	commands.Register("users", config.HandlerUsers, config.CommandInfo{
+		Description: "List users",
+	})
+	commands.Register("agg", config.HandlerAgg, config.CommandInfo{
+		Description: "Fetch due feeds in a loop until stopped",
+		Usage:       "agg <time between requests> [flags]",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Flags: []config.Flag{
+			{Name: "workers", Default: 1, Usage: "number of feeds fetched in parallel"},
+			{Name: "batch", Default: 0, Usage: "number of feeds claimed each tick (default: workers)"},
+			{Name: "min-interval", Default: time.Duration(0), Usage: "shortest time between fetches of one feed (default: time between requests)"},
+			{Name: "max-interval", Default: 24 * time.Hour, Usage: "longest time between fetches of one feed"},
+			{Name: "max-failures", Default: 10, Usage: "disable a feed after this many failures in a row, 0 for never"},
+		},
+	})
+	commands.Register("addfeed", config.MiddlewareToken(config.ScopeWrite, config.HandlerAddFeed), config.CommandInfo{
+		Description: "Add a feed and follow it",
+		Usage:       "addfeed <name> <url>",
+		MinArgs:     2,
+		MaxArgs:     2,
+	})
+	commands.Register("feeds", config.HandlerFeeds, config.CommandInfo{
+		Description: "List feeds, show failing ones, or re-enable one",
+		Usage:       "feeds [health | enable <url>]",
+		MaxArgs:     2,
+		ArgWords:    []string{"health", "enable"},
+	})
+	commands.Register("follow", config.MiddlewareToken(config.ScopeWrite, config.HandlerFollow), config.CommandInfo{
+		Description: "Follow an existing feed",
+		Usage:       "follow <url>",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Complete:    config.CompleteFeeds,
+	})
+	commands.Register("following", config.MiddlewareToken(config.ScopeRead, config.HandlerFollowing), config.CommandInfo{
+		Description: "List the feeds you follow",
+	})
+	commands.Register("unfollow", config.MiddlewareToken(config.ScopeWrite, config.HandlerUnfollow), config.CommandInfo{
+		Description: "Stop following a feed",
+		Usage:       "unfollow <url>",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Complete:    config.CompleteFollowedFeeds,
+	})
+	commands.Register("browse", config.MiddlewareToken(config.ScopeRead, config.HandlerBrowse), config.CommandInfo{
+		Description: "Show posts from the feeds you follow",
+		Usage:       "browse [limit] [flags]",
+		MaxArgs:     1,
+		Flags: []config.Flag{
+			{Name: "unread", Default: false, Usage: "only show posts you haven't read"},
+			{Name: "feed", Default: "", Usage: "only show posts from this feed (url or name)"},
+			{Name: "since", Default: "", Usage: "only show posts from this date on (YYYY-MM-DD or RFC3339)"},
+			{Name: "until", Default: "", Usage: "only show posts before this date (YYYY-MM-DD or RFC3339)"},
+			{Name: "page", Default: 1, Usage: "page of results to show, counting from 1"},
+			{Name: "offset", Default: -1, Usage: "number of posts to skip, overrides --page"},
+			{Name: "sort", Default: "published", Usage: "sort by published or fetched time"},
+			{Name: "asc", Default: false, Usage: "show oldest first"},
+		},
+	})
+	commands.Register("read", config.MiddlewareToken(config.ScopeWrite, config.HandlerRead), config.CommandInfo{
+		Description: "Mark a post read",
+		Usage:       "read <post id|url>",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Complete:    config.CompletePosts,
+	})
+	commands.Register("unread", config.MiddlewareToken(config.ScopeWrite, config.HandlerUnread), config.CommandInfo{
+		Description: "Mark a post unread",
+		Usage:       "unread <post id|url>",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Complete:    config.CompletePosts,
+	})
+	commands.Register("markall", config.MiddlewareToken(config.ScopeWrite, config.HandlerMarkAll), config.CommandInfo{
+		Description: "Mark many posts read at once",
+		Usage:       "markall read [flags]",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Flags: []config.Flag{
+			{Name: "feed", Default: "", Usage: "only mark posts from this feed"},
+			{Name: "before", Default: "", Usage: "only mark posts published before this date (YYYY-MM-DD or RFC3339)"},
+		},
+		ArgWords: []string{"read"},
+	})
+	commands.Register("star", config.MiddlewareToken(config.ScopeWrite, config.HandlerStar), config.CommandInfo{
+		Description: "Save a post for later",
+		Usage:       "star <post id|url>",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Complete:    config.CompletePosts,
+	})
+	commands.Register("unstar", config.MiddlewareToken(config.ScopeWrite, config.HandlerUnstar), config.CommandInfo{
+		Description: "Remove a post from your starred posts",
+		Usage:       "unstar <post id|url>",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Complete:    config.CompletePosts,
+	})
+	commands.Register("starred", config.MiddlewareToken(config.ScopeRead, config.HandlerStarred), config.CommandInfo{
+		Description: "List your starred posts",
+		Usage:       "starred [flags]",
+		Flags: []config.Flag{
+			{Name: "limit", Default: 20, Usage: "number of posts to show"},
+		},
+	})
+	commands.Register("search", config.MiddlewareToken(config.ScopeRead, config.HandlerSearch), config.CommandInfo{
+		Description: "Search posts from the feeds you follow",
+		Usage:       "search <query> [flags]",
+		MinArgs:     1,
+		MaxArgs:     -1,
+		Flags: []config.Flag{
+			{Name: "feed", Default: "", Usage: "only search posts from this feed (url or name)"},
+			{Name: "since", Default: "", Usage: "only search posts published since this date (YYYY-MM-DD or RFC3339)"},
+			{Name: "limit", Default: 10, Usage: "number of results to show"},
+		},
+	})
+	commands.Register("import", config.MiddlewareToken(config.ScopeWrite, config.HandlerImport), config.CommandInfo{
+		Description: "Add and follow the feeds in an OPML file",
+		Usage:       "import <file.opml>",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Complete:    config.CompleteFiles,
+	})
+	commands.Register("export", config.MiddlewareToken(config.ScopeRead, config.HandlerExport), config.CommandInfo{
+		Description: "Write subscriptions out as OPML, or your posts as a feed",
+		Usage:       "export opml|feed [flags]",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Flags: []config.Flag{
+			{Name: "user", Default: "", Usage: "user whose follows or posts are exported (admin only, default: you)"},
+			{Name: "all", Default: false, Usage: "opml: export every feed instead of one user's follows"},
+			{Name: "format", Default: "atom", Usage: "feed: atom or rss"},
+			{Name: "limit", Default: 50, Usage: "feed: number of posts to include"},
+			{Name: "link", Default: "", Usage: "feed: url the feed will be published at"},
+		},
+		ArgWords: []string{"opml", "feed"},
+	})
+	commands.Register("migrate", config.HandlerMigrate, config.CommandInfo{
+		Description: "Apply or roll back the database schema",
+		Usage:       "migrate up|down|status|version [flags]",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Flags: []config.Flag{
+			{Name: "to", Default: -1, Usage: "version to migrate to (default: latest for up, previous for down)"},
+		},
+		ArgWords: []string{"up", "down", "status", "version"},
+	})
+	commands.Register("serve", config.HandlerServe, config.CommandInfo{
+		Description: "Serve the JSON API and the Fever sync API over HTTP",
+		Usage:       "serve [flags]",
+		Flags: []config.Flag{
+			{Name: "addr", Default: "127.0.0.1:8080", Usage: "address to listen on, use :8080 to listen on every interface"},
+		},
+	})
+	commands.Register("fever", config.MiddlewareLoggedIn(config.HandlerFever), config.CommandInfo{
+		Description: "Let Fever API clients like Reeder sync with your account",
+		Usage:       "fever enable|disable",
+		MinArgs:     1,
+		MaxArgs:     1,
+		ArgWords:    []string{"enable", "disable"},
+	})
+	commands.Register("token", config.MiddlewareLoggedIn(config.HandlerToken), config.CommandInfo{
+		Description: "Manage API tokens for scripts and the HTTP API",
+		Usage:       "token create <name> | list | revoke <name|id> [flags]",
+		MinArgs:     1,
+		MaxArgs:     2,
+		Flags: []config.Flag{
+			{Name: "scope", Default: "read", Usage: "create: read, write or admin"},
+			{Name: "expires", Default: time.Duration(0), Usage: "create: how long the token lasts, 0 for never"},
+		},
+		ArgWords: []string{"create", "list", "revoke"},
+	})
+	commands.Register("completion", commands.HandlerCompletion, config.CommandInfo{
+		Description: "Print a shell completion script",
+		Usage:       "completion bash|zsh|fish",
+		MinArgs:     1,
+		MaxArgs:     1,
+		ArgWords:    []string{"bash", "zsh", "fish"},
+		NoDB:        true,
+	})
+	commands.Register("__complete", commands.HandlerComplete, config.CommandInfo{
+		Usage:   "__complete <command> [args]",
+		MaxArgs: -1,
+		Hidden:  true,
+		// connects itself, quietly giving up without a config
+		NoDB: true,
+	})
 
-	args := os.Args
-	if len(args) < 2 {
-		fmt.Println("Error: not enough arguments")
-		os.Exit(1)
+	if len(args) == 0 {
+		args = append(args, "help")
 	}
-	cmdName := args[1]
-	cmdArgs := args[2:]
+	cmdName := args[0]
+	cmdArgs := args[1:]
 
 	cmd := config.Command{
 		Name: cmdName,
@@ -1,5 +1,4 @@
 #!/bin/bash
+# drops and recreates the schema in the database from ~/.gatorconfig.json
 
-cd sql/schema
-goose postgres "postgres://frankiebrown:@localhost:5432/gator" down
-goose postgres "postgres://frankiebrown:@localhost:5432/gator" up
\ No newline at end of file
+go run . migrate down --to 0 && go run . migrate up
\ No newline at end of file
@@ -0,0 +1,33 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (id, user_id, name, token_hash, scope, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetAPITokensForUser :many
+SELECT * FROM api_tokens
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: RevokeAPIToken :execrows
+DELETE FROM api_tokens
+-- ref is the token's name or id
+WHERE user_id = sqlc.arg(user_id) AND (id::text = sqlc.arg(ref)::text OR name = sqlc.arg(ref)::text);
+
+-- name: GetAPITokenUser :one
+SELECT sqlc.embed(api_tokens), sqlc.embed(users) FROM api_tokens
+JOIN users ON users.id = api_tokens.user_id
+WHERE api_tokens.token_hash = $1
+AND (api_tokens.expires_at IS NULL OR api_tokens.expires_at > NOW());
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = NOW()
+WHERE id = $1;
\ No newline at end of file
@@ -11,19 +11,20 @@ VALUES (
 RETURNING *;
 
 -- name: GetFeeds :many
-SELECT feeds.name, feeds.url, users.name as username
+SELECT feeds.name, feeds.url, feeds.site_url, users.name as username
 FROM feeds
 JOIN users on feeds.user_id = users.id;
 
 -- name: CreateFeedFollow :one
 WITH inserted_feed_follow AS (
-    INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+    INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id, category)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6
 )
     RETURNING *
 )
@@ -42,7 +43,7 @@ WHERE url = $1;
 
 -- name: GetFeedFollowsUser :many
 SELECT
-feed_follows.*, feeds.name as feed_name, users.name as user_name
+feed_follows.*, feeds.name as feed_name, feeds.url as feed_url, feeds.site_url as feed_site_url, users.name as user_name
 FROM feed_follows
 INNER JOIN feeds ON feeds.id = feed_follows.feed_id
 INNER JOIN users ON users.id = feed_follows.user_id
@@ -63,4 +64,83 @@ WHERE id = $1;
 -- name: GetNextFeedToFetch :one
 SELECT * FROM feeds
 ORDER BY last_fetched_at NULLS FIRST
-LIMIT 1;
\ No newline at end of file
+LIMIT 1;
+
+-- name: UpdateFeedCache :exec
+UPDATE feeds
+SET etag = $2,
+last_modified = $3,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: ClaimFeedsToFetch :many
+-- next_fetch_at is pushed out as a lease so other agg processes skip these
+-- feeds while they're being fetched, ScheduleFeedFetch replaces it after
+UPDATE feeds
+SET last_fetched_at = NOW(),
+next_fetch_at = NOW() + INTERVAL '10 minutes',
+updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE NOT disabled
+    AND (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ScheduleFeedFetch :exec
+UPDATE feeds
+SET next_fetch_at = NOW() + (sqlc.arg(interval_seconds)::int * INTERVAL '1 second'),
+updated_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: UpdateFeedHints :exec
+UPDATE feeds
+SET ttl_minutes = $2,
+skip_hours = $3,
+skip_days = $4,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET consecutive_failures = 0,
+last_status = $2,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: RecordFeedFailure :one
+UPDATE feeds
+SET consecutive_failures = consecutive_failures + 1,
+last_error = sqlc.arg(last_error),
+last_error_at = NOW(),
+last_status = sqlc.arg(last_status),
+disabled = disabled OR (sqlc.arg(max_failures)::int > 0 AND consecutive_failures + 1 >= sqlc.arg(max_failures)::int),
+updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetUnhealthyFeeds :many
+SELECT * FROM feeds
+WHERE consecutive_failures > 0 OR disabled
+ORDER BY disabled DESC, consecutive_failures DESC, name;
+
+-- name: EnableFeed :execrows
+UPDATE feeds
+SET disabled = FALSE,
+consecutive_failures = 0,
+next_fetch_at = NULL,
+updated_at = NOW()
+WHERE url = $1;
+
+-- name: GetFeedFollow :one
+SELECT * FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2;
+
+-- name: SetFeedSiteURL :exec
+UPDATE feeds
+SET site_url = $2,
+updated_at = NOW()
+WHERE id = $1;
\ No newline at end of file
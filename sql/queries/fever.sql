@@ -0,0 +1,71 @@
+-- name: SetFeverKey :exec
+INSERT INTO fever_keys (user_id, api_key, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id) DO UPDATE SET api_key = EXCLUDED.api_key, created_at = EXCLUDED.created_at;
+
+-- name: DeleteFeverKey :execrows
+DELETE FROM fever_keys
+WHERE user_id = $1;
+
+-- name: GetUserByFeverKey :one
+SELECT users.* FROM users
+JOIN fever_keys ON fever_keys.user_id = users.id
+WHERE fever_keys.api_key = $1;
+
+-- name: GetFeverFeeds :many
+SELECT feeds.seq, feeds.name, feeds.url, feeds.site_url, feeds.last_fetched_at, feed_follows.category
+FROM feed_follows
+JOIN feeds ON feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.seq;
+
+-- name: GetFeverItems :many
+SELECT posts.seq, feeds.seq AS feed_seq, posts.title, posts.url, posts.description,
+COALESCE(posts.published_at, posts.created_at)::timestamp AS posted_at,
+(post_reads.read_at IS NOT NULL)::bool AS is_read,
+(post_stars.starred_at IS NOT NULL)::bool AS is_saved
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+LEFT JOIN post_stars ON post_stars.post_id = posts.id AND post_stars.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(since_id)::bigint IS NULL OR posts.seq > sqlc.narg(since_id))
+AND (sqlc.narg(max_id)::bigint IS NULL OR posts.seq < sqlc.narg(max_id))
+AND (sqlc.narg(with_ids)::bigint[] IS NULL OR posts.seq = ANY(sqlc.narg(with_ids)::bigint[]))
+-- since_id pages forwards, everything else newest first
+ORDER BY
+    CASE WHEN sqlc.narg(since_id)::bigint IS NOT NULL THEN posts.seq END ASC,
+    posts.seq DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: CountFeverItems :one
+SELECT COUNT(*) FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = $1;
+
+-- name: GetUnreadPostSeqs :many
+SELECT posts.seq FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+AND post_reads.read_at IS NULL
+ORDER BY posts.seq;
+
+-- name: GetStarredPostSeqs :many
+SELECT posts.seq FROM posts
+JOIN post_stars ON post_stars.post_id = posts.id
+WHERE post_stars.user_id = $1
+ORDER BY posts.seq;
+
+-- name: GetPostBySeq :one
+SELECT * FROM posts
+WHERE seq = $1;
+
+-- name: GetFeedBySeq :one
+SELECT * FROM feeds
+WHERE seq = $1;
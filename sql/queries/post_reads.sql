@@ -0,0 +1,23 @@
+-- name: MarkPostRead :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostUnread :exec
+DELETE FROM post_reads
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: MarkAllPostsRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, NOW()
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(feed_url)::text IS NULL OR feeds.url = sqlc.narg(feed_url))
+AND (sqlc.narg(before)::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < sqlc.narg(before))
+ON CONFLICT (user_id, post_id) DO NOTHING;
\ No newline at end of file
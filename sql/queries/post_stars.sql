@@ -0,0 +1,19 @@
+-- name: StarPost :exec
+INSERT INTO post_stars (user_id, post_id, starred_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: UnstarPost :execrows
+DELETE FROM post_stars
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: GetStarredPostsForUser :many
+SELECT posts.*, post_stars.starred_at FROM post_stars
+JOIN posts ON posts.id = post_stars.post_id
+WHERE post_stars.user_id = $1
+ORDER BY post_stars.starred_at DESC
+LIMIT $2;
\ No newline at end of file
@@ -13,11 +13,61 @@ VALUES (
 RETURNING *;
 
 -- name: GetPostsForUser :many
-SELECT posts.* FROM posts
+SELECT posts.*, (post_reads.read_at IS NOT NULL)::bool AS is_read,
+feeds.name AS feed_name, feeds.url AS feed_url, feeds.site_url AS feed_site_url
+FROM posts
 JOIN feeds ON feeds.id = posts.feed_id
 JOIN feed_follows ON feeds.id = feed_follows.feed_id
-WHERE feed_follows.user_id = $1
-ORDER BY posts.published_at DESC
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND (NOT sqlc.arg(unread_only)::bool OR post_reads.read_at IS NULL)
+AND (sqlc.narg(feed)::text IS NULL OR feeds.url = sqlc.narg(feed) OR feeds.name = sqlc.narg(feed))
+AND (sqlc.narg(since)::timestamp IS NULL OR (CASE WHEN sqlc.arg(sort_fetched)::bool THEN posts.created_at ELSE COALESCE(posts.published_at, posts.created_at) END) >= sqlc.narg(since))
+AND (sqlc.narg(until)::timestamp IS NULL OR (CASE WHEN sqlc.arg(sort_fetched)::bool THEN posts.created_at ELSE COALESCE(posts.published_at, posts.created_at) END) < sqlc.narg(until))
+-- undated posts sort by when they were fetched, id breaks any ties
+ORDER BY
+    CASE WHEN sqlc.arg(sort_asc)::bool THEN (CASE WHEN sqlc.arg(sort_fetched)::bool THEN posts.created_at ELSE COALESCE(posts.published_at, posts.created_at) END) END ASC,
+    CASE WHEN NOT sqlc.arg(sort_asc)::bool THEN (CASE WHEN sqlc.arg(sort_fetched)::bool THEN posts.created_at ELSE COALESCE(posts.published_at, posts.created_at) END) END DESC,
+    CASE WHEN sqlc.arg(sort_asc)::bool THEN posts.id END ASC,
+    CASE WHEN NOT sqlc.arg(sort_asc)::bool THEN posts.id END DESC
+LIMIT sqlc.arg(page_size)
+OFFSET sqlc.arg(page_offset);
+
+
+-- name: GetRecentPostTimes :many
+SELECT COALESCE(published_at, created_at)::timestamp AS posted_at
+FROM posts
+WHERE feed_id = $1
+ORDER BY posted_at DESC
 LIMIT $2;
 
+-- name: CountUnreadPostsForUser :one
+SELECT COUNT(*) FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+AND post_reads.read_at IS NULL;
 
+-- name: GetPost :one
+SELECT * FROM posts
+WHERE id = $1;
+
+-- name: GetPostByURL :one
+SELECT * FROM posts
+WHERE url = $1;
+
+-- name: SearchPostsForUser :many
+SELECT posts.id, posts.title, posts.url, posts.published_at, feeds.name AS feed_name,
+ts_rank(posts.search_vector, tsq)::real AS rank,
+ts_headline('english', posts.title || ' ' || coalesce(posts.description, ''), tsq,
+    'StartSel=**, StopSel=**, MaxFragments=2, MaxWords=20, MinWords=5')::text AS snippet
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id,
+websearch_to_tsquery('english', sqlc.arg(query)) tsq
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND posts.search_vector @@ tsq
+AND (sqlc.narg(feed)::text IS NULL OR feeds.url = sqlc.narg(feed) OR feeds.name = sqlc.narg(feed))
+AND (sqlc.narg(since)::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= sqlc.narg(since))
+ORDER BY rank DESC, posts.published_at DESC NULLS LAST
+LIMIT sqlc.arg(page_size);
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3
+);
+
+-- name: GetSessionUser :one
+SELECT users.* FROM sessions
+JOIN users ON users.id = sessions.user_id
+WHERE sessions.token_hash = $1
+AND sessions.expires_at > NOW();
+
+-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE token_hash = $1;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM sessions
+WHERE user_id = $1;
\ No newline at end of file
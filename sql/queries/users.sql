@@ -1,10 +1,13 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, password_hash, is_admin)
 VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    -- the first user on a new install looks after it
+    NOT EXISTS (SELECT 1 FROM users)
 )
 RETURNING *;
 
@@ -16,4 +19,14 @@ WHERE name = $1;
 DELETE FROM users;
 
 -- name: GetUsers :many
-SELECT name FROM users;
\ No newline at end of file
+SELECT name FROM users;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: HasAdmin :one
+SELECT EXISTS (
+    SELECT 1 FROM users WHERE is_admin
+);
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN etag TEXT NULL,
+ADD COLUMN last_modified TEXT NULL;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN etag,
+DROP COLUMN last_modified;
\ No newline at end of file
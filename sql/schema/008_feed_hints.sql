@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN ttl_minutes INTEGER NULL,
+ADD COLUMN skip_hours INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN skip_days INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN ttl_minutes,
+DROP COLUMN skip_hours,
+DROP COLUMN skip_days;
\ No newline at end of file
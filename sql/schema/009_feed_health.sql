@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN last_error TEXT NULL,
+ADD COLUMN last_error_at TIMESTAMP NULL,
+ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN last_status INTEGER NULL,
+ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN last_error,
+DROP COLUMN last_error_at,
+DROP COLUMN consecutive_failures,
+DROP COLUMN last_status,
+DROP COLUMN disabled;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- +goose Up
+-- integer ids for sync clients, which can't use uuids
+ALTER TABLE feeds
+ADD COLUMN seq BIGSERIAL UNIQUE;
+
+ALTER TABLE posts
+ADD COLUMN seq BIGSERIAL UNIQUE;
+
+CREATE TABLE fever_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    api_key TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE fever_keys;
+
+ALTER TABLE posts
+DROP COLUMN seq;
+
+ALTER TABLE feeds
+DROP COLUMN seq;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN password_hash TEXT;
+
+CREATE TABLE sessions (
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE sessions;
+
+ALTER TABLE users
+DROP COLUMN password_hash;
\ No newline at end of file
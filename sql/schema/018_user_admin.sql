@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+
+-- the first user with a password already looks after an existing install
+UPDATE users
+SET is_admin = true
+WHERE id = (
+    SELECT id FROM users
+    WHERE password_hash IS NOT NULL
+    ORDER BY created_at
+    LIMIT 1
+);
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN is_admin;
\ No newline at end of file
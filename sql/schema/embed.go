@@ -0,0 +1,7 @@
+// Package schema holds the goose migrations, embedded so gator can migrate itself
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS